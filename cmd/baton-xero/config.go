@@ -3,11 +3,16 @@ package main
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/conductorone/baton-sdk/pkg/cli"
+	"github.com/conductorone/baton-xero/pkg/xero"
 	"github.com/spf13/cobra"
 )
 
+var tenantIdPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
 // config defines the external configuration required for the connector to run.
 type config struct {
 	cli.BaseConfig `mapstructure:",squash"` // Puts the base config options in the same place as the connector options
@@ -16,13 +21,147 @@ type config struct {
 	RefreshToken     string `mapstructure:"refresh-token"`
 	XeroClientId     string `mapstructure:"xero-client-id"`
 	XeroClientSecret string `mapstructure:"xero-client-secret"`
+
+	AccessTokenFile      string `mapstructure:"token-file"`
+	RefreshTokenFile     string `mapstructure:"refresh-token-file"`
+	XeroClientIdFile     string `mapstructure:"xero-client-id-file"`
+	XeroClientSecretFile string `mapstructure:"xero-client-secret-file"`
+	CredentialProcess    string `mapstructure:"credential-process"`
+
+	XeroApiUrl         string `mapstructure:"xero-api-url"`
+	XeroIdentityUrl    string `mapstructure:"xero-identity-url"`
+	XeroConnectionsUrl string `mapstructure:"xero-connections-url"`
+
+	TokenStorePath          string `mapstructure:"token-store-path"`
+	TokenStoreEncryptionKey string `mapstructure:"token-store-encryption-key"`
+
+	TenantIds          []string `mapstructure:"tenant-ids"`
+	ExcludeTenantIds   []string `mapstructure:"exclude-tenant-ids"`
+	TenantNamePatterns []string `mapstructure:"tenant-name-patterns"`
+
+	DailyCallBudget int    `mapstructure:"daily-call-budget"`
+	SyncStatePath   string `mapstructure:"sync-state-path"`
+
+	RecordHttpPath string `mapstructure:"record-http"`
+	ReplayHttpPath string `mapstructure:"replay-http"`
+}
+
+// tenantFilter returns the tenant selection described by the configuration.
+func (cfg *config) tenantFilter() *xero.TenantFilter {
+	return &xero.TenantFilter{
+		Allow:        cfg.TenantIds,
+		Deny:         cfg.ExcludeTenantIds,
+		NamePatterns: cfg.TenantNamePatterns,
+	}
+}
+
+// endpoints returns the Xero base URLs, with the configured overrides applied.
+func (cfg *config) endpoints() (*xero.Endpoints, error) {
+	return xero.ParseEndpoints(cfg.XeroApiUrl, cfg.XeroIdentityUrl, cfg.XeroConnectionsUrl)
+}
+
+// tokenStore returns the store persisting rotated refresh tokens, or nil if none is configured.
+func (cfg *config) tokenStore() xero.TokenStore {
+	if cfg.TokenStorePath == "" {
+		return nil
+	}
+
+	return xero.NewFileTokenStore(cfg.TokenStorePath, cfg.TokenStoreEncryptionKey)
+}
+
+// syncState returns the state persisting the users fetched by each sync, or nil if none is configured.
+func (cfg *config) syncState() *xero.SyncState {
+	if cfg.SyncStatePath == "" {
+		return nil
+	}
+
+	return xero.NewSyncState(cfg.SyncStatePath)
+}
+
+// secretSources returns the sources the credentials are read from on every login, or nil if
+// only literal credentials are configured. A file path of "-" reads the secret from stdin.
+func (cfg *config) secretSources() *xero.SecretSources {
+	if cfg.AccessTokenFile == "" && cfg.RefreshTokenFile == "" && cfg.XeroClientIdFile == "" &&
+		cfg.XeroClientSecretFile == "" && cfg.CredentialProcess == "" {
+		return nil
+	}
+
+	sources := &xero.SecretSources{}
+
+	if cfg.CredentialProcess != "" {
+		process := xero.NewCredentialProcess(cfg.CredentialProcess)
+		sources.ClientId = process.ClientId()
+		sources.ClientSecret = process.ClientSecret()
+		sources.AccessToken = process.AccessToken()
+		sources.RefreshToken = process.RefreshToken()
+	}
+
+	stdin := &xero.StdinSecret{}
+	fileSource := func(path string, fallback xero.SecretSource) xero.SecretSource {
+		switch path {
+		case "":
+			return fallback
+		case "-":
+			return stdin
+		default:
+			return &xero.FileSecret{Path: path}
+		}
+	}
+
+	sources.ClientId = fileSource(cfg.XeroClientIdFile, sources.ClientId)
+	sources.ClientSecret = fileSource(cfg.XeroClientSecretFile, sources.ClientSecret)
+	sources.AccessToken = fileSource(cfg.AccessTokenFile, sources.AccessToken)
+	sources.RefreshToken = fileSource(cfg.RefreshTokenFile, sources.RefreshToken)
+
+	return sources
+}
+
+// clientCredentials returns the client id and secret, read from their sources if configured.
+func (cfg *config) clientCredentials(ctx context.Context) (string, string, error) {
+	clientId, clientSecret := cfg.XeroClientId, cfg.XeroClientSecret
+
+	sources := cfg.secretSources()
+	if sources == nil {
+		return clientId, clientSecret, nil
+	}
+
+	for _, s := range []struct {
+		source xero.SecretSource
+		target *string
+	}{
+		{sources.ClientId, &clientId},
+		{sources.ClientSecret, &clientSecret},
+	} {
+		if s.source == nil {
+			continue
+		}
+
+		secret, err := s.source.Secret(ctx)
+		if err != nil {
+			return "", "", err
+		}
+
+		if secret != "" {
+			*s.target = secret
+		}
+	}
+
+	return clientId, clientSecret, nil
 }
 
 // validateConfig is run after the configuration is loaded, and should return an error if it isn't valid.
 func validateConfig(ctx context.Context, cfg *config) error {
-	isOAuthSet := (cfg.XeroClientId != "" && cfg.XeroClientSecret != "")
-	isTokenSet := cfg.AccessToken != ""
-	isRefreshTokenSet := cfg.RefreshToken != ""
+	if err := validateSecretSources(cfg); err != nil {
+		return err
+	}
+
+	// a credential process may provide any of the credentials
+	isProcessSet := cfg.CredentialProcess != ""
+	isClientIdSet := cfg.XeroClientId != "" || cfg.XeroClientIdFile != "" || isProcessSet
+	isClientSecretSet := cfg.XeroClientSecret != "" || cfg.XeroClientSecretFile != "" || isProcessSet
+	isOAuthSet := isClientIdSet && isClientSecretSet
+	isTokenSet := cfg.AccessToken != "" || cfg.AccessTokenFile != "" || isProcessSet
+	isRefreshTokenSet := cfg.RefreshToken != "" || cfg.RefreshTokenFile != ""
 
 	if !isOAuthSet && !isTokenSet {
 		return fmt.Errorf("either client id and secret or a token must be set, use --help for more information")
@@ -32,6 +171,83 @@ func validateConfig(ctx context.Context, cfg *config) error {
 		return fmt.Errorf("refresh token requires client id and secret to be set, use --help for more information")
 	}
 
+	if cfg.TokenStorePath != "" && !isOAuthSet {
+		return fmt.Errorf("token store requires client id and secret to be set, use --help for more information")
+	}
+
+	if cfg.TokenStoreEncryptionKey != "" && cfg.TokenStorePath == "" {
+		return fmt.Errorf("token store encryption key requires a token store path to be set, use --help for more information")
+	}
+
+	if _, err := cfg.endpoints(); err != nil {
+		return err
+	}
+
+	if cfg.RecordHttpPath != "" && cfg.ReplayHttpPath != "" {
+		return fmt.Errorf("only one of record-http and replay-http can be set, use --help for more information")
+	}
+
+	if cfg.DailyCallBudget < 0 || cfg.DailyCallBudget > xero.DayLimit {
+		return fmt.Errorf("daily call budget must be between 0 and %d, use --help for more information", xero.DayLimit)
+	}
+
+	return validateTenantFilter(cfg)
+}
+
+func validateSecretSources(cfg *config) error {
+	secrets := []struct {
+		name    string
+		literal string
+		file    string
+	}{
+		{"token", cfg.AccessToken, cfg.AccessTokenFile},
+		{"refresh-token", cfg.RefreshToken, cfg.RefreshTokenFile},
+		{"xero-client-id", cfg.XeroClientId, cfg.XeroClientIdFile},
+		{"xero-client-secret", cfg.XeroClientSecret, cfg.XeroClientSecretFile},
+	}
+
+	fromStdin := 0
+	for _, secret := range secrets {
+		if secret.literal != "" && secret.file != "" {
+			return fmt.Errorf("only one of %s and %s-file can be set, use --help for more information", secret.name, secret.name)
+		}
+
+		if secret.file == "-" {
+			fromStdin++
+		}
+	}
+
+	if fromStdin > 1 {
+		return fmt.Errorf("only one secret can be read from stdin, use --help for more information")
+	}
+
+	return nil
+}
+
+func validateTenantFilter(cfg *config) error {
+	excluded := make(map[string]bool)
+	for _, id := range cfg.ExcludeTenantIds {
+		if !tenantIdPattern.MatchString(id) {
+			return fmt.Errorf("invalid tenant id %q in exclude-tenant-ids, expected a GUID", id)
+		}
+		excluded[strings.ToLower(id)] = true
+	}
+
+	for _, id := range cfg.TenantIds {
+		if !tenantIdPattern.MatchString(id) {
+			return fmt.Errorf("invalid tenant id %q in tenant-ids, expected a GUID", id)
+		}
+		if excluded[strings.ToLower(id)] {
+			return fmt.Errorf("tenant id %q is both included and excluded", id)
+		}
+	}
+
+	for _, pattern := range cfg.TenantNamePatterns {
+		if err := xero.ValidateNamePattern(pattern); err != nil {
+			return fmt.Errorf("invalid tenant name pattern %q: %w", pattern, err)
+		}
+	}
+
 	return nil
 }
 
@@ -41,4 +257,33 @@ func cmdFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().String("refresh-token", "", "The Xero refresh token used to exchange for a new access token. ($BATON_REFRESH_TOKEN)")
 	cmd.PersistentFlags().String("xero-client-id", "", "The Xero client ID used to connect to the Xero API. ($BATON_XERO_CLIENT_ID)")
 	cmd.PersistentFlags().String("xero-client-secret", "", "The Xero client secret used to connect to the Xero API. ($BATON_XERO_CLIENT_SECRET)")
+	cmd.PersistentFlags().String("token-file", "", "Path of a file containing the Xero access token, - reads it from stdin. ($BATON_TOKEN_FILE)")
+	cmd.PersistentFlags().String("refresh-token-file", "", "Path of a file containing the Xero refresh token, - reads it from stdin. ($BATON_REFRESH_TOKEN_FILE)")
+	cmd.PersistentFlags().String("xero-client-id-file", "", "Path of a file containing the Xero client ID, - reads it from stdin. ($BATON_XERO_CLIENT_ID_FILE)")
+	cmd.PersistentFlags().String("xero-client-secret-file", "", "Path of a file containing the Xero client secret, - reads it from stdin. ($BATON_XERO_CLIENT_SECRET_FILE)")
+	cmd.PersistentFlags().String(
+		"credential-process",
+		"",
+		"Command printing the Xero credentials as JSON with Version 1 and any of ClientId, ClientSecret, AccessToken and RefreshToken. ($BATON_CREDENTIAL_PROCESS)",
+	)
+	cmd.PersistentFlags().String("xero-api-url", "", "Override the base URL of the Xero Accounting API. ($BATON_XERO_API_URL)")
+	cmd.PersistentFlags().String("xero-identity-url", "", "Override the base URL of the Xero identity server. ($BATON_XERO_IDENTITY_URL)")
+	cmd.PersistentFlags().String("xero-connections-url", "", "Override the URL of the Xero connections endpoint. ($BATON_XERO_CONNECTIONS_URL)")
+	cmd.PersistentFlags().String("token-store-path", "", "Path of the file persisting the refresh token Xero rotates on every use. ($BATON_TOKEN_STORE_PATH)")
+	cmd.PersistentFlags().String("token-store-encryption-key", "", "Key used to encrypt the token store file, preferably set through the environment. ($BATON_TOKEN_STORE_ENCRYPTION_KEY)")
+	cmd.PersistentFlags().StringSlice("tenant-ids", nil, "Only sync the Xero tenants with these IDs. ($BATON_TENANT_IDS)")
+	cmd.PersistentFlags().StringSlice("exclude-tenant-ids", nil, "Never sync the Xero tenants with these IDs. ($BATON_EXCLUDE_TENANT_IDS)")
+	cmd.PersistentFlags().StringSlice("tenant-name-patterns", nil, "Only sync the Xero tenants whose name matches one of these glob patterns. ($BATON_TENANT_NAME_PATTERNS)")
+	cmd.PersistentFlags().Int(
+		"daily-call-budget",
+		0,
+		"Maximum number of Xero API calls per tenant and day, 0 means the Xero limit of 5000. ($BATON_DAILY_CALL_BUDGET)",
+	)
+	cmd.PersistentFlags().String("sync-state-path", "", "Path of the file persisting the users fetched by each sync, so that later syncs only fetch the users modified since. ($BATON_SYNC_STATE_PATH)")
+	cmd.PersistentFlags().String(
+		"record-http",
+		"",
+		"Path of a cassette file every Xero request and response is recorded to, with credentials and tenant IDs redacted. ($BATON_RECORD_HTTP)",
+	)
+	cmd.PersistentFlags().String("replay-http", "", "Path of a cassette file replayed instead of sending requests to Xero. ($BATON_REPLAY_HTTP)")
 }
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/conductorone/baton-xero/pkg/connector"
+	"github.com/conductorone/baton-xero/pkg/xero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const loginTimeout = 5 * time.Minute
+
+type authorizationResult struct {
+	code string
+	err  error
+}
+
+// loginCmd returns the command obtaining a refresh token with the authorization code flow and PKCE.
+func loginCmd(ctx context.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Authorize the Xero app in a browser and save the refresh token to the token store",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, redirectURI, err := loadLoginConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			return runLogin(ctx, cmd, cfg, redirectURI)
+		},
+	}
+
+	cmd.Flags().String("redirect-uri", "http://localhost:8910/callback", "The redirect URI registered for the Xero app, must point to localhost. ($BATON_REDIRECT_URI)")
+
+	return cmd
+}
+
+func loadLoginConfig(cmd *cobra.Command) (*config, string, error) {
+	v := viper.New()
+	v.SetEnvPrefix("baton")
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+
+	if err := v.BindPFlags(cmd.InheritedFlags()); err != nil {
+		return nil, "", err
+	}
+	if err := v.BindPFlags(cmd.Flags()); err != nil {
+		return nil, "", err
+	}
+
+	cfg := &config{}
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, "", err
+	}
+
+	if cfg.TokenStorePath == "" {
+		return nil, "", fmt.Errorf("login requires a token store path to be set, use --help for more information")
+	}
+
+	return cfg, v.GetString("redirect-uri"), nil
+}
+
+func runLogin(ctx context.Context, cmd *cobra.Command, cfg *config, redirectURI string) error {
+	redirect, err := url.Parse(redirectURI)
+	if err != nil {
+		return fmt.Errorf("invalid redirect uri: %w", err)
+	}
+
+	if redirect.Scheme != "http" || !isLoopbackHost(redirect.Hostname()) {
+		return fmt.Errorf("redirect uri must be an http://localhost address")
+	}
+
+	clientId, clientSecret, err := cfg.clientCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the refresh token is only usable by syncs, which refresh it with the client secret
+	if clientId == "" || clientSecret == "" {
+		return fmt.Errorf("login requires the client id and secret to be set, use --help for more information")
+	}
+
+	endpoints, err := cfg.endpoints()
+	if err != nil {
+		return err
+	}
+
+	verifier, challenge, err := xero.NewPKCE()
+	if err != nil {
+		return err
+	}
+
+	state, err := xero.NewState()
+	if err != nil {
+		return err
+	}
+
+	listeners, err := listenRedirect(redirect)
+	if err != nil {
+		return fmt.Errorf("failed to listen for the redirect: %w", err)
+	}
+
+	results := make(chan authorizationResult, 1)
+	mux := redirectHandler(redirect, state, results)
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				select {
+				case results <- authorizationResult{err: err}:
+				default:
+				}
+			}
+		}(listener)
+	}
+	defer server.Close()
+
+	scopes := append(append([]string{}, xero.IdentityScopes...), connector.RequiredScopes()...)
+
+	cmd.PrintErrln("Open the following URL in a browser to authorize baton-xero:")
+	cmd.PrintErrln(xero.AuthorizeURL(endpoints, clientId, redirectURI, state, challenge, scopes))
+
+	ctx, cancel := context.WithTimeout(ctx, loginTimeout)
+	defer cancel()
+
+	var result authorizationResult
+	select {
+	case result = <-results:
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for the authorization redirect")
+	}
+
+	if result.err != nil {
+		return result.err
+	}
+
+	res, err := xero.AuthorizationCodeFlow(ctx, http.DefaultClient, endpoints, result.code, redirectURI, verifier, clientId, clientSecret)
+	if err != nil {
+		return err
+	}
+
+	if res.RefreshToken == "" {
+		return fmt.Errorf("no refresh token was issued, the offline_access scope is required")
+	}
+
+	err = cfg.tokenStore().Save(ctx, &xero.StoredToken{
+		RefreshToken: res.RefreshToken,
+		IssuedAt:     time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	cmd.PrintErrf("Refresh token saved to %s\n", cfg.TokenStorePath)
+
+	return nil
+}
+
+// redirectHandler serves the redirect URI, sending the authorization code or the error it
+// carries to results. A redirect URI without a path is served at the root.
+func redirectHandler(redirect *url.URL, state string, results chan<- authorizationResult) *http.ServeMux {
+	pattern := redirect.Path
+	if pattern == "" {
+		pattern = "/"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		var result authorizationResult
+		switch {
+		case query.Get("state") != state:
+			result.err = fmt.Errorf("authorization response has an unexpected state")
+		case query.Get("error") != "":
+			result.err = fmt.Errorf("authorization failed: %s", query.Get("error"))
+		case query.Get("code") == "":
+			result.err = fmt.Errorf("authorization response has no code")
+		default:
+			result.code = query.Get("code")
+		}
+
+		if result.err != nil {
+			http.Error(w, result.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "baton-xero is authorized, you can close this window.")
+		}
+
+		select {
+		case results <- result:
+		default:
+		}
+	})
+
+	return mux
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// listenRedirect listens on the host and port of the redirect URI. localhost is resolved by
+// browsers to either loopback address, so both are listened on when available.
+func listenRedirect(redirect *url.URL) ([]net.Listener, error) {
+	port := redirect.Port()
+	if port == "" {
+		port = "80"
+	}
+
+	hosts := []string{redirect.Hostname()}
+	if redirect.Hostname() == "localhost" {
+		hosts = []string{"127.0.0.1", "::1"}
+	}
+
+	var (
+		listeners []net.Listener
+		errs      []error
+	)
+	for _, host := range hosts {
+		listener, err := net.Listen("tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		listeners = append(listeners, listener)
+	}
+
+	if len(listeners) == 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return listeners, nil
+}
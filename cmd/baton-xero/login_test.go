@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		redirectURI string
+		request     string
+		wantStatus  int
+		wantCode    string
+	}{
+		{name: "path", redirectURI: "http://localhost:8910/callback", request: "/callback?state=s&code=c", wantStatus: http.StatusOK, wantCode: "c"},
+		{name: "no path", redirectURI: "http://localhost:8911", request: "/?state=s&code=c", wantStatus: http.StatusOK, wantCode: "c"},
+		{name: "root path", redirectURI: "http://127.0.0.1:8911/", request: "/?state=s&code=c", wantStatus: http.StatusOK, wantCode: "c"},
+		{name: "other path", redirectURI: "http://localhost:8910/callback", request: "/other?state=s&code=c", wantStatus: http.StatusNotFound},
+		{name: "unexpected state", redirectURI: "http://localhost:8911", request: "/?state=other&code=c", wantStatus: http.StatusBadRequest},
+		{name: "denied", redirectURI: "http://localhost:8911", request: "/?state=s&error=access_denied", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redirect, err := url.Parse(tt.redirectURI)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			results := make(chan authorizationResult, 1)
+			rec := httptest.NewRecorder()
+			redirectHandler(redirect, "s", results).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.request, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			if tt.wantStatus == http.StatusNotFound {
+				return
+			}
+
+			result := <-results
+			if result.code != tt.wantCode || (result.err == nil) != (tt.wantCode != "") {
+				t.Fatalf("got code %q and error %v, want code %q", result.code, result.err, tt.wantCode)
+			}
+		})
+	}
+}
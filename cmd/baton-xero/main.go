@@ -27,6 +27,7 @@ func main() {
 
 	cmd.Version = version
 	cmdFlags(cmd)
+	cmd.AddCommand(loginCmd(ctx))
 
 	err = cmd.Execute()
 	if err != nil {
@@ -38,7 +39,26 @@ func main() {
 func getConnector(ctx context.Context, cfg *config) (types.ConnectorServer, error) {
 	l := ctxzap.Extract(ctx)
 
-	xeroConnector, err := connector.New(ctx, cfg.XeroClientId, cfg.XeroClientSecret, cfg.AccessToken, cfg.RefreshToken)
+	endpoints, err := cfg.endpoints()
+	if err != nil {
+		l.Error("error creating connector", zap.Error(err))
+		return nil, err
+	}
+
+	xeroConnector, err := connector.New(ctx, &connector.Options{
+		ClientId:        cfg.XeroClientId,
+		ClientSecret:    cfg.XeroClientSecret,
+		Token:           cfg.AccessToken,
+		RefreshToken:    cfg.RefreshToken,
+		TenantFilter:    cfg.tenantFilter(),
+		TokenStore:      cfg.tokenStore(),
+		Endpoints:       endpoints,
+		Secrets:         cfg.secretSources(),
+		DailyCallBudget: cfg.DailyCallBudget,
+		SyncState:       cfg.syncState(),
+		RecordPath:      cfg.RecordHttpPath,
+		ReplayPath:      cfg.ReplayHttpPath,
+	})
 	if err != nil {
 		l.Error("error creating connector", zap.Error(err))
 		return nil, err
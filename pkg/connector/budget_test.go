@@ -0,0 +1,156 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-xero/pkg/xero"
+)
+
+// newBudgetTestConnector returns a connector allowing budget calls per tenant and day, whose
+// deferred pages are handed back without waiting.
+func newBudgetTestConnector(t *testing.T, budget int) *Xero {
+	t.Helper()
+
+	delay := deferRetryDelay
+	deferRetryDelay = 0
+	t.Cleanup(func() { deferRetryDelay = delay })
+
+	x, _ := newTestConnector(t, func(opts *Options) { opts.DailyCallBudget = budget }, testTenants()...)
+
+	return x
+}
+
+// assertDeferred checks that a page was deferred by the budget, returning its token.
+func assertDeferred[T any](t *testing.T, items []T, token string, annos annotations.Annotations, err error) string {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("the spent budget failed the page: %v", err)
+	}
+
+	if len(items) != 0 || token == "" {
+		t.Fatalf("got %d items and token %q, want an empty page to fetch again", len(items), token)
+	}
+
+	rateLimit := &v2.RateLimitDescription{}
+	ok, err := annos.Pick(rateLimit)
+	if err != nil || !ok {
+		t.Fatalf("the deferred page has no rate limit: %v", err)
+	}
+
+	if rateLimit.Status != v2.RateLimitDescription_STATUS_OVERLIMIT || !rateLimit.ResetAt.AsTime().After(time.Now()) {
+		t.Fatalf("unexpected rate limit %v", rateLimit)
+	}
+
+	return token
+}
+
+func TestBudgetDefersPages(t *testing.T) {
+	ctx := context.Background()
+
+	// listing the orgs spends the budget of both tenants
+	x := newBudgetTestConnector(t, 1)
+	orgs := listOrgs(t, x)
+
+	t.Run("org list", func(t *testing.T) {
+		items, token, annos, err := orgBuilder(x.client, x.snapshot).List(ctx, nil, &pagination.Token{})
+		token = assertDeferred(t, items, token, annos, err)
+
+		// the token points to the first tenant again
+		bag := &pagination.Bag{}
+		if err := bag.Unmarshal(token); err != nil || bag.Current().ResourceID != tenantDemo {
+			t.Fatalf("token %q does not point to tenant %s: %v", token, tenantDemo, err)
+		}
+	})
+
+	t.Run("user list", func(t *testing.T) {
+		parentId := &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: orgDemo}
+
+		items, token, annos, err := userBuilder(x.client, x.snapshot).List(ctx, parentId, &pagination.Token{})
+		if token = assertDeferred(t, items, token, annos, err); token != "1" {
+			t.Fatalf("got token %q, want the first page", token)
+		}
+	})
+
+	t.Run("org grants", func(t *testing.T) {
+		items, token, annos, err := orgBuilder(x.client, x.snapshot).Grants(ctx, orgs[0], &pagination.Token{})
+		assertDeferred(t, items, token, annos, err)
+	})
+
+	t.Run("role list", func(t *testing.T) {
+		items, token, annos, err := roleBuilder(x.client, x.snapshot).List(ctx, nil, &pagination.Token{})
+		assertDeferred(t, items, token, annos, err)
+	})
+
+	t.Run("role grants", func(t *testing.T) {
+		role, err := roleResource(ctx, roleById(standard))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		items, token, annos, err := roleBuilder(x.client, x.snapshot).Grants(ctx, role, &pagination.Token{})
+		token = assertDeferred(t, items, token, annos, err)
+
+		// the deferred page is still deferred when fetched again
+		items, token, annos, err = roleBuilder(x.client, x.snapshot).Grants(ctx, role, &pagination.Token{Token: token})
+		assertDeferred(t, items, token, annos, err)
+	})
+}
+
+func TestBudgetOptionalLookups(t *testing.T) {
+	ctx := context.Background()
+
+	// the budget of 10 calls keeps 1 for required calls
+	x := newBudgetTestConnector(t, 10)
+	for i := 0; i < 9; i++ {
+		listOrgs(t, x)
+	}
+
+	conns := listConnections(t, x)
+
+	// a resumed sync looks the orgs up again, which the reserve is not spent on
+	x.snapshot.reset()
+
+	items, token, annos, err := connectionBuilder(x.client, x.snapshot).Grants(ctx, conns[0], &pagination.Token{})
+	assertDeferred(t, items, token, annos, err)
+
+	parentId := &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: orgDemo}
+	users, token, annos, err := userBuilder(x.client, x.snapshot).List(ctx, parentId, &pagination.Token{})
+	assertDeferred(t, users, token, annos, err)
+
+	// the users themselves are fetched from the reserve
+	x.snapshot.setTenant(orgDemo, tenantDemo)
+
+	users, _ = listUsers(t, x, orgDemo)
+	assertIds(t, resourceIds(users), userAlice, userBob, userCarol)
+
+	// which is now spent
+	var budgetErr *xero.BudgetExhaustedError
+	if _, _, err := x.client.GetUsers(ctx, tenantDemo, nil); !errors.As(err, &budgetErr) {
+		t.Fatalf("got error %v, want the budget spent", err)
+	}
+}
+
+func TestBudgetWaitEndsWithSync(t *testing.T) {
+	x := newBudgetTestConnector(t, 1)
+	listOrgs(t, x)
+
+	deferRetryDelay = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	items, token, annos, err := orgBuilder(x.client, x.snapshot).List(ctx, nil, &pagination.Token{})
+	assertDeferred(t, items, token, annos, err)
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("the deferred page waited %s after the sync ended", elapsed)
+	}
+}
@@ -0,0 +1,178 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-xero/pkg/xero"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	connected = "connected"
+
+	organisationTenantType = "ORGANISATION"
+)
+
+type connectionResourceType struct {
+	resourceType *v2.ResourceType
+	client       *xero.Client
+	snapshot     *snapshot
+}
+
+func (c *connectionResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return c.resourceType
+}
+
+// Create a new connector resource for a Xero app connection.
+func connectionResource(ctx context.Context, conn *xero.Connection) (*v2.Resource, error) {
+	profile := map[string]interface{}{
+		"id":               conn.Id,
+		"tenant_id":        conn.TenantId,
+		"tenant_type":      conn.TenantType,
+		"tenant_name":      conn.TenantName,
+		"auth_event_id":    conn.AuthEventId,
+		"created_date_utc": conn.CreatedDateUtc.String(),
+		"updated_date_utc": conn.UpdatedDateUtc.String(),
+	}
+
+	resource, err := resource.NewAppResource(
+		conn.TenantName,
+		resourceTypeConnection,
+		conn.Id,
+		[]resource.AppTraitOption{
+			resource.WithAppProfile(profile),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// List returns every connection of the app, including those to tenants excluded by the
+// tenant filter, so that they can be reviewed and revoked.
+func (c *connectionResourceType) List(ctx context.Context, _ *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	conns, err := c.client.GetConnections(ctx)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("xero-connector: failed to list connections: %w", err)
+	}
+
+	var rv []*v2.Resource
+	for _, conn := range conns {
+		connCopy := conn
+
+		cr, err := connectionResource(ctx, &connCopy)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, cr)
+	}
+
+	return rv, "", nil, nil
+}
+
+func (c *connectionResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	var rv []*v2.Entitlement
+
+	assignmentOptions := []ent.EntitlementOption{
+		ent.WithGrantableTo(resourceTypeOrg),
+		ent.WithDisplayName(fmt.Sprintf("%s Connection", resource.DisplayName)),
+		ent.WithDescription(fmt.Sprintf("Organization %s is connected to the Xero app", resource.DisplayName)),
+	}
+
+	rv = append(rv, ent.NewAssignmentEntitlement(resource, connected, assignmentOptions...))
+
+	return rv, "", nil, nil
+}
+
+// Grants returns the organization the connection gives the app access to.
+// Connections to other tenant types, like practices, and to tenants excluded by the tenant
+// filter have no organization resource.
+func (c *connectionResourceType) Grants(ctx context.Context, connection *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	trait, err := resource.GetAppTrait(connection)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	conn := xero.Connection{Id: connection.Id.Resource}
+	conn.TenantType, _ = resource.GetProfileStringValue(trait.Profile, "tenant_type")
+	conn.TenantId, _ = resource.GetProfileStringValue(trait.Profile, "tenant_id")
+	conn.TenantName, _ = resource.GetProfileStringValue(trait.Profile, "tenant_name")
+	if conn.TenantType != organisationTenantType || conn.TenantId == "" || !c.client.Selects(conn) {
+		return nil, "", nil, nil
+	}
+
+	orgId, rateLimit, err := c.snapshot.orgOf(ctx, conn.TenantId)
+	if annos, ok := deferPage(ctx, err); ok {
+		return nil, retryToken, annos, nil
+	}
+
+	annos := rateLimitAnnotations(rateLimit)
+	if skipTenant(ctx, err, zap.String("tenant_id", conn.TenantId)) {
+		return nil, "", annos, nil
+	}
+	if err != nil {
+		return nil, "", annos, err
+	}
+
+	rv := []*v2.Grant{
+		grant.NewGrant(
+			connection,
+			connected,
+			&v2.ResourceId{
+				ResourceType: resourceTypeOrg.Id,
+				Resource:     orgId,
+			},
+		),
+	}
+
+	return rv, "", annos, nil
+}
+
+func (c *connectionResourceType) Grant(_ context.Context, _ *v2.Resource, _ *v2.Entitlement) (annotations.Annotations, error) {
+	return nil, status.Error(codes.Unimplemented, "xero-connector: connections can only be created by authorizing the app in Xero")
+}
+
+// Revoke disconnects the app from the organization of the connection.
+func (c *connectionResourceType) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	entitlement := grant.Entitlement
+	if entitlement.Resource.Id.ResourceType != resourceTypeConnection.Id {
+		return nil, fmt.Errorf("xero-connector: only connections can be revoked")
+	}
+
+	connectionId := entitlement.Resource.Id.Resource
+	err := c.client.DeleteConnection(ctx, connectionId)
+	if err != nil {
+		return nil, fmt.Errorf("xero-connector: failed to delete connection %s: %w", connectionId, err)
+	}
+
+	l.Info(
+		"xero-connector: disconnected app from tenant",
+		zap.String("connection_id", connectionId),
+		zap.String("principal_id", grant.Principal.Id.Resource),
+	)
+
+	return nil, nil
+}
+
+func connectionBuilder(client *xero.Client, snapshot *snapshot) *connectionResourceType {
+	return &connectionResourceType{
+		resourceType: resourceTypeConnection,
+		client:       client,
+		snapshot:     snapshot,
+	}
+}
@@ -0,0 +1,107 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-xero/pkg/xero"
+)
+
+func newFilteredTestConnector(t *testing.T, filter *xero.TenantFilter) *Xero {
+	t.Helper()
+
+	x, _ := newTestConnector(t, func(opts *Options) { opts.TenantFilter = filter }, testTenants()...)
+
+	return x
+}
+
+func listConnections(t *testing.T, x *Xero) []*v2.Resource {
+	t.Helper()
+
+	c := connectionBuilder(x.client, x.snapshot)
+	conns, _ := listAll(t, func(ctx context.Context, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+		return c.List(ctx, nil, pToken)
+	})
+
+	return conns
+}
+
+func connectionGrants(t *testing.T, x *Xero, conn *v2.Resource) []*v2.Grant {
+	t.Helper()
+
+	c := connectionBuilder(x.client, x.snapshot)
+	grants, _ := listAll(t, func(ctx context.Context, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+		return c.Grants(ctx, conn, pToken)
+	})
+
+	return grants
+}
+
+func TestConnectionListIgnoresTenantFilter(t *testing.T) {
+	x := newFilteredTestConnector(t, &xero.TenantFilter{Allow: []string{tenantAcme}})
+
+	conns := listConnections(t, x)
+
+	if len(conns) != 2 {
+		t.Fatalf("got %d connections, want 2", len(conns))
+	}
+
+	trait, err := resource.GetAppTrait(conns[0])
+	if err != nil {
+		t.Fatalf("no app trait: %v", err)
+	}
+
+	for key, want := range map[string]string{
+		"tenant_id":   tenantDemo,
+		"tenant_type": organisationTenantType,
+		"tenant_name": "Demo Company",
+	} {
+		if got, _ := resource.GetProfileStringValue(trait.Profile, key); got != want {
+			t.Fatalf("got %s %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestConnectionGrantsOrg(t *testing.T) {
+	x, _ := newTestConnector(t, nil, testTenants()...)
+
+	conns := listConnections(t, x)
+
+	// the orgs are not listed first, so they are looked up in their tenant
+	assertIds(t, grantPrincipals(connectionGrants(t, x, conns[0])), orgDemo)
+	assertIds(t, grantPrincipals(connectionGrants(t, x, conns[1])), orgAcme)
+}
+
+func TestConnectionGrantsSkipFilteredTenant(t *testing.T) {
+	x := newFilteredTestConnector(t, &xero.TenantFilter{Allow: []string{tenantAcme}})
+
+	conns := listConnections(t, x)
+
+	assertIds(t, grantPrincipals(connectionGrants(t, x, conns[0])))
+	assertIds(t, grantPrincipals(connectionGrants(t, x, conns[1])), orgAcme)
+}
+
+func TestConnectionRevoke(t *testing.T) {
+	x, _ := newTestConnector(t, nil, testTenants()...)
+
+	conns := listConnections(t, x)
+	grants := connectionGrants(t, x, conns[0])
+	if len(grants) != 1 {
+		t.Fatalf("got %d grants, want 1", len(grants))
+	}
+
+	c := connectionBuilder(x.client, x.snapshot)
+	if _, err := c.Revoke(context.Background(), grants[0]); err != nil {
+		t.Fatalf("failed to revoke connection: %v", err)
+	}
+
+	assertIds(t, resourceIds(listConnections(t, x)), conns[1].Id.Resource)
+
+	if _, err := c.Revoke(context.Background(), grants[0]); err == nil {
+		t.Fatal("revoking a deleted connection succeeded")
+	}
+}
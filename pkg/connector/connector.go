@@ -10,19 +10,47 @@ import (
 	"github.com/conductorone/baton-sdk/pkg/uhttp"
 	"github.com/conductorone/baton-xero/pkg/xero"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"go.uber.org/zap"
 )
 
 type Xero struct {
-	client *xero.Client
+	client   *xero.Client
+	scopes   []string
+	snapshot *snapshot
+}
+
+// Options configures the Xero connector.
+type Options struct {
+	ClientId     string
+	ClientSecret string
+	Token        string
+	RefreshToken string
+	TenantFilter *xero.TenantFilter
+	// TokenStore persists rotated refresh tokens, it is optional.
+	TokenStore xero.TokenStore
+	// Endpoints overrides the base URLs of the Xero services, nil means the production ones.
+	Endpoints *xero.Endpoints
+	// Secrets are the sources the credentials are read from on every login, they are optional.
+	Secrets *xero.SecretSources
+	// DailyCallBudget caps the calls made to each tenant per day, 0 means the Xero daily limit.
+	DailyCallBudget int
+	// SyncState persists the users fetched by each sync, so that later syncs only fetch the users
+	// modified since. It is optional.
+	SyncState *xero.SyncState
+	// RecordPath is the cassette file every Xero request and response is recorded to, it is optional.
+	RecordPath string
+	// ReplayPath is the cassette file Xero responses are served from instead of sending requests,
+	// it is optional.
+	ReplayPath string
 }
 
 func (x *Xero) ResourceSyncers(ctx context.Context) []connectorbuilder.ResourceSyncer {
 	return []connectorbuilder.ResourceSyncer{
-		orgBuilder(x.client),
-		userBuilder(x.client),
-		roleBuilder(x.client),
+		orgBuilder(x.client, x.snapshot),
+		userBuilder(x.client, x.snapshot),
+		roleBuilder(x.client, x.snapshot),
+		orgRoleBuilder(x.client, x.snapshot),
+		connectionBuilder(x.client, x.snapshot),
 	}
 }
 
@@ -35,31 +63,71 @@ func (x *Xero) Metadata(ctx context.Context) (*v2.ConnectorMetadata, error) {
 }
 
 // Validate hits the Xero API to validate that the configured credentials are valid and compatible.
+// It checks the access token, the connected tenants and every endpoint used by the syncers, and
+// reports every problem found instead of only the first one. Only problems affecting every
+// tenant fail validation, those of a single tenant are logged as warnings.
 func (x *Xero) Validate(ctx context.Context) (annotations.Annotations, error) {
-	// should be able to list users
-	_, err := x.client.GetOrganizations(ctx)
-	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "Provided credentials are invalid")
-	}
+	// the SDK validates the connector whenever a sync starts or resumes, so the data shared
+	// by the syncers is fetched again for every sync
+	x.snapshot.reset()
 
-	return nil, nil
+	report := x.validate(ctx)
+
+	return nil, report.err(ctx)
 }
 
 // New returns the Xero connector.
-func New(ctx context.Context, clientId, clientSecret, token, refreshToken string) (*Xero, error) {
+func New(ctx context.Context, opts *Options) (*Xero, error) {
 	httpClient, err := uhttp.NewClient(ctx, uhttp.WithLogger(true, ctxzap.Extract(ctx)))
 	if err != nil {
 		return nil, err
 	}
 
+	tokenStore := opts.TokenStore
+	syncState := opts.SyncState
+
+	switch {
+	case opts.ReplayPath != "":
+		replay, err := xero.NewReplayTransport(opts.ReplayPath)
+		if err != nil {
+			return nil, err
+		}
+		httpClient.Transport = replay
+
+		// replayed responses carry redacted tokens and placeholder IDs, which must not be persisted
+		tokenStore = nil
+		syncState = nil
+
+		ctxzap.Extract(ctx).Info("xero-connector: replaying recorded responses", zap.String("path", opts.ReplayPath))
+
+	case opts.RecordPath != "":
+		httpClient.Transport = xero.NewRecordingTransport(httpClient.Transport, opts.RecordPath)
+
+		ctxzap.Extract(ctx).Info("xero-connector: recording requests", zap.String("path", opts.RecordPath))
+	}
+
+	scopes := RequiredScopes()
+
+	auth := xero.NewAuth(opts.Token, opts.RefreshToken, opts.ClientId, opts.ClientSecret, scopes)
+	auth.Store = tokenStore
+	auth.Secrets = opts.Secrets
+
 	client, err := xero.NewClient(
 		ctx,
 		httpClient,
-		xero.NewAuth(token, refreshToken, clientId, clientSecret),
+		auth,
+		xero.WithTenantFilter(opts.TenantFilter),
+		xero.WithEndpoints(opts.Endpoints),
+		xero.WithDailyBudget(xero.NewDailyBudget(opts.DailyCallBudget)),
+		xero.WithSyncState(syncState),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
 
-	return &Xero{client}, nil
+	return &Xero{
+		client:   client,
+		scopes:   scopes,
+		snapshot: newSnapshot(client),
+	}, nil
 }
@@ -1,14 +1,34 @@
 package connector
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-xero/pkg/xero"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const ResourcesPageSize = 50
 
+const tenantPageType = "tenant"
+
+// retryToken is the page token returned by a listing that is not paged to have its page
+// fetched again.
+const retryToken = "retry"
+
+// deferRetryDelay is the longest a deferred page waits before it is handed back to the sync loop.
+var deferRetryDelay = time.Minute
+
 func titleCase(s string) string {
 	titleCaser := cases.Title(language.English)
 
@@ -20,3 +40,131 @@ func annotationsForUserResourceType() annotations.Annotations {
 	annos.Update(&v2.SkipEntitlementsAndGrants{})
 	return annos
 }
+
+// rateLimitAnnotations reports the Xero rate limit state to the sync loop, so that it
+// can pace its requests before the limit is exceeded.
+func rateLimitAnnotations(rateLimit *v2.RateLimitDescription) annotations.Annotations {
+	annos := annotations.Annotations{}
+	if rateLimit != nil {
+		annos.WithRateLimiting(rateLimit)
+	}
+	return annos
+}
+
+// deferPage reports whether err is a tenant that cannot be read before a known time: its
+// daily call budget is spent, or Xero rate limited it for longer than the client waits, like
+// when its daily limit is spent. The page is then not failed: the caller returns it empty
+// with its own token, so that the sync loop fetches it again later, or checkpoints it when
+// the sync is interrupted, for a later run to resume. The wait is bounded to keep the sync
+// responsive, and the annotations report when the tenant can be read again.
+func deferPage(ctx context.Context, err error) (annotations.Annotations, bool) {
+	var (
+		budgetErr *xero.BudgetExhaustedError
+		apiErr    *xero.APIError
+		limit     int
+		resetAt   time.Time
+	)
+
+	switch {
+	case errors.As(err, &budgetErr):
+		limit = budgetErr.Limit
+		resetAt = budgetErr.ResetAt
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests:
+		limit = xero.MinuteLimit
+		if apiErr.RateLimitProblem == "day" {
+			limit = xero.DayLimit
+		}
+		resetAt = time.Now().Add(apiErr.RetryAfter)
+	default:
+		return nil, false
+	}
+
+	ctxzap.Extract(ctx).Info(
+		"xero-connector: deferring page until the tenant can be read again",
+		zap.Time("reset_at", resetAt),
+		zap.Error(err),
+	)
+
+	delay := time.Until(resetAt)
+	if delay > deferRetryDelay {
+		delay = deferRetryDelay
+	}
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	annos := annotations.Annotations{}
+	annos.WithRateLimiting(&v2.RateLimitDescription{
+		Status:    v2.RateLimitDescription_STATUS_OVERLIMIT,
+		Limit:     int64(limit),
+		Remaining: 0,
+		ResetAt:   timestamppb.New(resetAt),
+	})
+
+	return annos, true
+}
+
+// skipTenant reports whether err is a tenant the app can no longer read, because access to it
+// was withdrawn or it is offline. Its resources are then left out of the sync with a warning,
+// rather than failing the sync of every other tenant.
+func skipTenant(ctx context.Context, err error, fields ...zap.Field) bool {
+	if !tenantUnavailable(err) {
+		return false
+	}
+
+	ctxzap.Extract(ctx).Warn(
+		"xero-connector: skipping tenant that cannot be read",
+		append(fields, zap.Error(err))...,
+	)
+
+	return true
+}
+
+// tenantUnavailable reports whether err is a tenant the app can no longer read.
+func tenantUnavailable(err error) bool {
+	var apiErr *xero.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return apiErr.StatusCode == http.StatusForbidden || apiErr.StatusCode == http.StatusServiceUnavailable
+}
+
+// tenantBag unmarshals the page token into a pagination bag. On the first page the bag
+// is seeded with one state per selected tenant, so that every page covers a single tenant,
+// whose listings are not paged.
+func tenantBag(ctx context.Context, client *xero.Client, pToken *pagination.Token) (*pagination.Bag, error) {
+	bag := &pagination.Bag{}
+
+	token := ""
+	if pToken != nil {
+		token = pToken.Token
+	}
+
+	if err := bag.Unmarshal(token); err != nil {
+		return nil, err
+	}
+
+	if token == "" {
+		conns, err := client.GetTenants(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("xero-connector: failed to list connections: %w", err)
+		}
+
+		// push in reverse so the tenants are visited in the order returned by Xero
+		for i := len(conns) - 1; i >= 0; i-- {
+			bag.Push(pagination.PageState{
+				ResourceTypeID: tenantPageType,
+				ResourceID:     conns[i].TenantId,
+			})
+		}
+	}
+
+	return bag, nil
+}
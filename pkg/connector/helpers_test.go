@@ -0,0 +1,199 @@
+package connector
+
+import (
+	"context"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-xero/pkg/xero"
+	"github.com/conductorone/baton-xero/pkg/xero/xerotest"
+)
+
+const (
+	tenantDemo   = "6d8d9c33-6b8c-4f27-9d5a-0a9d2b2c1a01"
+	tenantAcme   = "8f1b2e44-1c3d-4e5f-8a9b-0c1d2e3f4a02"
+	orgDemo      = "b1a3c5e7-0000-4000-8000-000000000001"
+	orgAcme      = "b1a3c5e7-0000-4000-8000-000000000002"
+	userAlice    = "a0000000-0000-4000-8000-000000000001"
+	userBob      = "a0000000-0000-4000-8000-000000000002"
+	userCarol    = "a0000000-0000-4000-8000-000000000003"
+	userDave     = "a0000000-0000-4000-8000-000000000004"
+	userErin     = "a0000000-0000-4000-8000-000000000007"
+	roleStandard = "STANDARD"
+	roleReadOnly = "READONLY"
+)
+
+func testTenants() []*xerotest.Tenant {
+	return []*xerotest.Tenant{
+		{
+			Id:           tenantDemo,
+			Name:         "Demo Company",
+			Organisation: xero.Organization{Id: orgDemo, Name: "Demo Company", Country: "NZ"},
+			Users: []xero.User{
+				{Id: userAlice, Email: "alice@example.com", FirstName: "Alice", LastName: "Smith", Role: roleStandard},
+				{Id: userBob, Email: "bob@example.com", FirstName: "Bob", LastName: "Jones", Role: roleReadOnly},
+				{Id: userCarol, Email: "carol@example.com", FirstName: "Carol", LastName: "White", Role: roleStandard},
+			},
+		},
+		{
+			Id:           tenantAcme,
+			Name:         "Acme Ltd",
+			Organisation: xero.Organization{Id: orgAcme, Name: "Acme Ltd", Country: "GB"},
+			Users: []xero.User{
+				{Id: userDave, Email: "dave@example.com", FirstName: "Dave", LastName: "Brown", Role: roleReadOnly},
+				{Id: userErin, Email: "erin@example.com", FirstName: "Erin", LastName: "Green", Role: "FINANCIALADVISER"},
+			},
+		},
+	}
+}
+
+// newTestConnector returns a connector logged in to a fake Xero serving the tenants.
+func newTestConnector(t *testing.T, override func(opts *Options), tenants ...*xerotest.Tenant) (*Xero, *xerotest.Server) {
+	t.Helper()
+
+	srv := xerotest.NewServer(tenants...)
+	t.Cleanup(srv.Close)
+
+	opts := &Options{
+		ClientId:     xerotest.ClientId,
+		ClientSecret: xerotest.ClientSecret,
+		Endpoints:    srv.Endpoints(),
+	}
+	if override != nil {
+		override(opts)
+	}
+
+	x, err := New(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("failed to create connector: %v", err)
+	}
+
+	return x, srv
+}
+
+// startSync validates the connector like the SDK does when a sync starts.
+func startSync(t *testing.T, x *Xero) {
+	t.Helper()
+
+	if _, err := x.Validate(context.Background()); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+}
+
+// listAll follows the page tokens of a List or Grants call until the last page.
+func listAll[T any](
+	t *testing.T,
+	list func(ctx context.Context, pToken *pagination.Token) ([]T, string, annotations.Annotations, error),
+) ([]T, int) {
+	t.Helper()
+
+	var (
+		all   []T
+		token string
+		pages int
+	)
+
+	for {
+		items, next, _, err := list(context.Background(), &pagination.Token{Token: token, Size: ResourcesPageSize})
+		if err != nil {
+			t.Fatalf("failed to list page %d: %v", pages+1, err)
+		}
+
+		all = append(all, items...)
+		pages++
+
+		if next == "" {
+			return all, pages
+		}
+
+		if pages > 100 {
+			t.Fatalf("pagination does not terminate, last token %q", next)
+		}
+
+		token = next
+	}
+}
+
+// listChildren lists the resources of a syncer under every organization of the fixtures.
+func listChildren(
+	t *testing.T,
+	list func(ctx context.Context, parentId *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error),
+	orgIds ...string,
+) ([]*v2.Resource, int) {
+	t.Helper()
+
+	if len(orgIds) == 0 {
+		orgIds = []string{orgDemo, orgAcme}
+	}
+
+	var (
+		all   []*v2.Resource
+		pages int
+	)
+
+	for _, orgId := range orgIds {
+		parentId := &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: orgId}
+
+		resources, n := listAll(t, func(ctx context.Context, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+			return list(ctx, parentId, pToken)
+		})
+
+		for _, r := range resources {
+			if r.ParentResourceId.GetResource() != orgId {
+				t.Fatalf("%s is not a child of org %s", r.Id.Resource, orgId)
+			}
+		}
+
+		all = append(all, resources...)
+		pages += n
+	}
+
+	return all, pages
+}
+
+func grantPrincipals(grants []*v2.Grant) []string {
+	ids := make([]string, 0, len(grants))
+	for _, g := range grants {
+		ids = append(ids, g.Principal.Id.Resource)
+	}
+
+	return ids
+}
+
+// grantsOf returns the grants of the entitlement of the resource.
+func grantsOf(grants []*v2.Grant, resource *v2.Resource, slug string) []*v2.Grant {
+	var rv []*v2.Grant
+	for _, g := range grants {
+		if g.Entitlement.Id == ent.NewEntitlementID(resource, slug) {
+			rv = append(rv, g)
+		}
+	}
+
+	return rv
+}
+
+func resourceIds(resources []*v2.Resource) []string {
+	ids := make([]string, 0, len(resources))
+	for _, r := range resources {
+		ids = append(ids, r.Id.Resource)
+	}
+
+	return ids
+}
+
+func assertIds(t *testing.T, got []string, want ...string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got ids %v, want %v", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got ids %v, want %v", got, want)
+		}
+	}
+}
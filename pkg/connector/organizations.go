@@ -7,13 +7,26 @@ import (
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
 	"github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/conductorone/baton-xero/pkg/xero"
+	"go.uber.org/zap"
+)
+
+const (
+	// member is the entitlement of every user of an organization, granted through the
+	// organization role they hold.
+	member = "member"
+	// subscriber is the entitlement of the user who owns the Xero subscription of an organization,
+	// and can change its plan, add paid features and transfer its ownership.
+	subscriber = "subscriber"
 )
 
 type orgResourceType struct {
 	resourceType *v2.ResourceType
 	client       *xero.Client
+	snapshot     *snapshot
 }
 
 func (o *orgResourceType) ResourceType(_ context.Context) *v2.ResourceType {
@@ -26,6 +39,10 @@ func orgResource(ctx context.Context, org *xero.Organization) (*v2.Resource, err
 		org.Name,
 		resourceTypeOrg,
 		org.Id,
+		resource.WithAnnotation(
+			&v2.ChildResourceType{ResourceTypeId: resourceTypeUser.Id},
+			&v2.ChildResourceType{ResourceTypeId: resourceTypeOrgRole.Id},
+		),
 	)
 	if err != nil {
 		return nil, err
@@ -34,38 +51,127 @@ func orgResource(ctx context.Context, org *xero.Organization) (*v2.Resource, err
 	return resource, nil
 }
 
-func (o *orgResourceType) List(ctx context.Context, _ *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
-	orgs, err := o.client.GetOrganizations(ctx)
+func (o *orgResourceType) List(ctx context.Context, _ *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	bag, err := tenantBag(ctx, o.client, pToken)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("xero-connector: failed to list orgs: %w", err)
+		return nil, "", nil, err
+	}
+
+	if bag.Current() == nil {
+		return nil, "", nil, nil
+	}
+
+	tenantId := bag.Current().ResourceID
+
+	orgs, rateLimit, err := o.client.GetOrganizations(ctx, tenantId)
+	if annos, ok := deferPage(ctx, err); ok {
+		token, err := bag.Marshal()
+		return nil, token, annos, err
+	}
+
+	annos := rateLimitAnnotations(rateLimit)
+	if skipTenant(ctx, err, zap.String("tenant_id", tenantId)) {
+		nextToken, err := bag.NextToken("")
+		return nil, nextToken, annos, err
+	}
+	if err != nil {
+		return nil, "", annos, fmt.Errorf("xero-connector: failed to list orgs: %w", err)
 	}
 
 	var rv []*v2.Resource
 	for _, org := range orgs {
 		orgCopy := org
 
+		o.snapshot.setTenant(org.Id, tenantId)
+
 		or, err := orgResource(ctx, &orgCopy)
 		if err != nil {
-			return nil, "", nil, err
+			return nil, "", annos, err
 		}
 
 		rv = append(rv, or)
 	}
 
-	return rv, "", nil, nil
+	nextToken, err := bag.NextToken("")
+	if err != nil {
+		return nil, "", annos, err
+	}
+
+	return rv, nextToken, annos, nil
 }
 
-func (o *orgResourceType) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+func (o *orgResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	memberOptions := []ent.EntitlementOption{
+		ent.WithGrantableTo(resourceTypeUser, resourceTypeOrgRole),
+		ent.WithDisplayName(fmt.Sprintf("%s Member", resource.DisplayName)),
+		ent.WithDescription(fmt.Sprintf("Member of the %s organization in Xero", resource.DisplayName)),
+	}
+
+	subscriberOptions := []ent.EntitlementOption{
+		ent.WithGrantableTo(resourceTypeUser),
+		ent.WithDisplayName(fmt.Sprintf("%s Subscriber", resource.DisplayName)),
+		ent.WithDescription(fmt.Sprintf("Owner of the Xero subscription of %s, who can change its plan, add paid features and transfer its ownership", resource.DisplayName)),
+	}
+
+	rv := []*v2.Entitlement{
+		ent.NewAssignmentEntitlement(resource, member, memberOptions...),
+		ent.NewAssignmentEntitlement(resource, subscriber, subscriberOptions...),
+	}
+
+	return rv, "", nil, nil
 }
 
-func (o *orgResourceType) Grants(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	return nil, "", nil, nil
+func (o *orgResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	_, users, rateLimit, err := o.snapshot.orgUsers(ctx, resource.Id.Resource)
+	if annos, ok := deferPage(ctx, err); ok {
+		return nil, retryToken, annos, nil
+	}
+
+	annos := rateLimitAnnotations(rateLimit)
+	if skipTenant(ctx, err, zap.String("org_id", resource.Id.Resource)) {
+		return nil, "", annos, nil
+	}
+	if err != nil {
+		return nil, "", annos, err
+	}
+
+	var rv []*v2.Grant
+
+	// every user holds exactly one role of the organization, so granting membership to the
+	// roles, expanding to their users, makes each user a member once and lets reviews walk
+	// from the organization to its roles and their users
+	for _, role := range heldRoles(users) {
+		rr, err := orgRoleResource(ctx, resource.Id, role)
+		if err != nil {
+			return nil, "", annos, err
+		}
+
+		rv = append(rv, grant.NewGrant(
+			resource,
+			member,
+			rr.Id,
+			grant.WithAnnotation(&v2.GrantExpandable{
+				EntitlementIds: []string{ent.NewEntitlementID(rr, role.id)},
+			}),
+		))
+	}
+
+	for _, user := range users {
+		if user.IsSubscriber {
+			rv = append(rv, grant.NewGrant(resource, subscriber, &v2.ResourceId{
+				ResourceType: resourceTypeUser.Id,
+				Resource:     user.Id,
+			}))
+		}
+	}
+
+	return rv, "", annos, nil
 }
 
-func orgBuilder(client *xero.Client) *orgResourceType {
+func orgBuilder(client *xero.Client, snapshot *snapshot) *orgResourceType {
 	return &orgResourceType{
 		resourceType: resourceTypeOrg,
 		client:       client,
+		snapshot:     snapshot,
 	}
 }
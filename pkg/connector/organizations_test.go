@@ -0,0 +1,199 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-xero/pkg/xero"
+	"github.com/conductorone/baton-xero/pkg/xero/xerotest"
+)
+
+func listOrgs(t *testing.T, x *Xero) []*v2.Resource {
+	t.Helper()
+
+	o := orgBuilder(x.client, x.snapshot)
+	orgs, _ := listAll(t, func(ctx context.Context, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+		return o.List(ctx, nil, pToken)
+	})
+
+	return orgs
+}
+
+func TestOrgListEveryTenant(t *testing.T) {
+	x, _ := newTestConnector(t, nil, testTenants()...)
+
+	orgs := listOrgs(t, x)
+
+	assertIds(t, resourceIds(orgs), orgDemo, orgAcme)
+	if orgs[0].DisplayName != "Demo Company" || orgs[1].DisplayName != "Acme Ltd" {
+		t.Fatalf("unexpected org names %q and %q", orgs[0].DisplayName, orgs[1].DisplayName)
+	}
+}
+
+func TestOrgListTenantFilter(t *testing.T) {
+	x := newFilteredTestConnector(t, &xero.TenantFilter{Allow: []string{tenantAcme}})
+
+	assertIds(t, resourceIds(listOrgs(t, x)), orgAcme)
+}
+
+func TestOrgListRetriesRateLimited(t *testing.T) {
+	x, srv := newTestConnector(t, nil, testTenants()...)
+	srv.Inject(xero.OrgsEndpoint, xerotest.Fault{StatusCode: http.StatusTooManyRequests, RetryAfter: 0, Times: 2})
+
+	assertIds(t, resourceIds(listOrgs(t, x)), orgDemo, orgAcme)
+
+	if got := srv.Requests(xero.OrgsEndpoint); got != 4 {
+		t.Fatalf("got %d requests to %s, want 4", got, xero.OrgsEndpoint)
+	}
+}
+
+func TestOrgListReportsRateLimit(t *testing.T) {
+	x, _ := newTestConnector(t, nil, testTenants()...)
+
+	_, _, annos, err := orgBuilder(x.client, x.snapshot).List(context.Background(), nil, &pagination.Token{})
+	if err != nil {
+		t.Fatalf("failed to list orgs: %v", err)
+	}
+
+	rateLimit := &v2.RateLimitDescription{}
+	ok, err := annos.Pick(rateLimit)
+	if err != nil || !ok {
+		t.Fatalf("no rate limit annotation: %v", err)
+	}
+
+	if rateLimit.Remaining != xero.MinuteLimit-1 {
+		t.Fatalf("got %d remaining calls, want %d", rateLimit.Remaining, xero.MinuteLimit-1)
+	}
+}
+
+func TestOrgListSkipsForbiddenTenant(t *testing.T) {
+	x, srv := newTestConnector(t, nil, testTenants()...)
+	srv.Inject(xero.OrgsEndpoint, xerotest.Fault{StatusCode: http.StatusForbidden})
+
+	// the first tenant is skipped, the others are still synced
+	assertIds(t, resourceIds(listOrgs(t, x)), orgAcme)
+}
+
+func TestOrgSubscriberGrant(t *testing.T) {
+	tenants := testTenants()
+	tenants[0].Users[2].IsSubscriber = true
+	tenants[1].Users[1].IsSubscriber = true
+
+	x, srv := newTestConnector(t, nil, tenants...)
+
+	o := orgBuilder(x.client, x.snapshot)
+	orgs, _ := listAll(t, func(ctx context.Context, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+		return o.List(ctx, nil, pToken)
+	})
+
+	entitlements, _, _, err := o.Entitlements(context.Background(), orgs[0], &pagination.Token{})
+	if err != nil {
+		t.Fatalf("failed to list entitlements: %v", err)
+	}
+
+	if len(entitlements) != 2 || entitlements[1].Slug != subscriber {
+		t.Fatalf("unexpected entitlements %v", entitlements)
+	}
+
+	grants, _, _, err := o.Grants(context.Background(), orgs[1], &pagination.Token{})
+	if err != nil {
+		t.Fatalf("failed to list grants: %v", err)
+	}
+
+	assertIds(t, grantPrincipals(grantsOf(grants, orgs[1], subscriber)), userErin)
+
+	// the org listed by the builder was looked up without another request
+	if got := srv.Requests(xero.OrgsEndpoint); got != 2 {
+		t.Fatalf("got %d requests to %s, want 2", got, xero.OrgsEndpoint)
+	}
+}
+
+func TestOrgSubscriberGrantAfterResume(t *testing.T) {
+	tenants := testTenants()
+	tenants[1].Users[0].IsSubscriber = true
+
+	x, _ := newTestConnector(t, nil, tenants...)
+
+	org, err := orgResource(context.Background(), &tenants[1].Organisation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grants, _, _, err := orgBuilder(x.client, x.snapshot).Grants(context.Background(), org, &pagination.Token{})
+	if err != nil {
+		t.Fatalf("failed to list grants: %v", err)
+	}
+
+	assertIds(t, grantPrincipals(grantsOf(grants, org, subscriber)), userDave)
+}
+
+func TestOrgParentOfUsers(t *testing.T) {
+	x, _ := newTestConnector(t, nil, testTenants()...)
+
+	orgs := listOrgs(t, x)
+
+	var children []string
+	for _, a := range orgs[0].Annotations {
+		crt := &v2.ChildResourceType{}
+		if a.MessageIs(crt) {
+			if err := a.UnmarshalTo(crt); err != nil {
+				t.Fatal(err)
+			}
+			children = append(children, crt.ResourceTypeId)
+		}
+	}
+
+	assertIds(t, children, resourceTypeUser.Id, resourceTypeOrgRole.Id)
+
+	users, _ := listUsers(t, x, orgAcme)
+	assertIds(t, resourceIds(users), userDave, userErin)
+
+	topLevel, _, _, err := userBuilder(x.client, x.snapshot).List(context.Background(), nil, &pagination.Token{})
+	if err != nil || len(topLevel) != 0 {
+		t.Fatalf("got users %v and error %v without a parent org", topLevel, err)
+	}
+}
+
+func TestOrgMemberGrants(t *testing.T) {
+	x, _ := newTestConnector(t, nil, testTenants()...)
+
+	orgs := listOrgs(t, x)
+
+	grants, _, _, err := orgBuilder(x.client, x.snapshot).Grants(context.Background(), orgs[0], &pagination.Token{})
+	if err != nil {
+		t.Fatalf("failed to list grants: %v", err)
+	}
+
+	members := grantsOf(grants, orgs[0], member)
+
+	// membership is granted to the roles held in the org, not to their users directly
+	assertIds(t, grantPrincipals(members), orgRoleResourceId(orgDemo, readOnly), orgRoleResourceId(orgDemo, standard))
+
+	for _, g := range members {
+		if g.Principal.Id.ResourceType != resourceTypeOrgRole.Id {
+			t.Fatalf("membership of %s is not granted to an org role", g.Principal.Id.Resource)
+		}
+
+		annos := annotations.Annotations(g.Annotations)
+		expandable := &v2.GrantExpandable{}
+		ok, err := annos.Pick(expandable)
+		if err != nil || !ok {
+			t.Fatalf("membership of %s is not expandable: %v", g.Principal.Id.Resource, err)
+		}
+
+		_, role, err := parseOrgRoleResourceId(g.Principal.Id.Resource)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := ent.NewEntitlementID(&v2.Resource{Id: g.Principal.Id}, role.id)
+		if len(expandable.EntitlementIds) != 1 || expandable.EntitlementIds[0] != want {
+			t.Fatalf("membership of %s expands %v, want %s", g.Principal.Id.Resource, expandable.EntitlementIds, want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-xero/pkg/xero"
+	"go.uber.org/zap"
+)
+
+// orgRoleResourceId returns the resource ID of a role of an organization. Unlike the shared
+// roles, these are scoped to their organization, as the users holding a role differ in each one.
+func orgRoleResourceId(orgId, roleId string) string {
+	return orgId + ":" + roleId
+}
+
+// parseOrgRoleResourceId returns the organization and the role of an organization role resource ID.
+func parseOrgRoleResourceId(id string) (string, xeroRole, error) {
+	orgId, roleId, ok := strings.Cut(id, ":")
+	if !ok || orgId == "" || roleId == "" {
+		return "", xeroRole{}, fmt.Errorf("xero-connector: invalid organization role id %q", id)
+	}
+
+	return orgId, roleById(roleId), nil
+}
+
+type orgRoleResourceType struct {
+	resourceType *v2.ResourceType
+	client       *xero.Client
+	snapshot     *snapshot
+}
+
+func (r *orgRoleResourceType) ResourceType(_ context.Context) *v2.ResourceType {
+	return r.resourceType
+}
+
+// Create a new connector resource for a Xero Role in an Organization.
+func orgRoleResource(ctx context.Context, orgId *v2.ResourceId, role xeroRole) (*v2.Resource, error) {
+	profile := map[string]interface{}{
+		"role_name":         role.id,
+		"organisation_role": role.value,
+		"org_id":            orgId.Resource,
+	}
+
+	resource, err := resource.NewRoleResource(
+		role.displayName,
+		resourceTypeOrgRole,
+		orgRoleResourceId(orgId.Resource, role.id),
+		[]resource.RoleTraitOption{
+			resource.WithRoleProfile(profile),
+		},
+		resource.WithDescription(role.description),
+		resource.WithParentResourceID(orgId),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// heldRoles returns the roles held by the users, in the order of rolesOf.
+func heldRoles(users []xero.User) []xeroRole {
+	held := make(map[string]bool)
+	for _, user := range users {
+		role, _ := roleByValue(user.Role)
+		held[role.id] = true
+	}
+
+	var rv []xeroRole
+	for _, role := range rolesOf(users) {
+		if held[role.id] {
+			rv = append(rv, role)
+		}
+	}
+
+	return rv
+}
+
+// List returns the roles of the catalog and those discovered on the users of the organization.
+func (r *orgRoleResourceType) List(ctx context.Context, parentId *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	// organization roles are listed under their organization
+	if parentId == nil || parentId.ResourceType != resourceTypeOrg.Id {
+		return nil, "", nil, nil
+	}
+
+	_, users, rateLimit, err := r.snapshot.orgUsers(ctx, parentId.Resource)
+	if annos, ok := deferPage(ctx, err); ok {
+		return nil, retryToken, annos, nil
+	}
+
+	annos := rateLimitAnnotations(rateLimit)
+	if skipTenant(ctx, err, zap.String("org_id", parentId.Resource)) {
+		return nil, "", annos, nil
+	}
+	if err != nil {
+		return nil, "", annos, err
+	}
+
+	var rv []*v2.Resource
+	for _, role := range rolesOf(users) {
+		rr, err := orgRoleResource(ctx, parentId, role)
+		if err != nil {
+			return nil, "", annos, err
+		}
+
+		rv = append(rv, rr)
+	}
+
+	return rv, "", annos, nil
+}
+
+func (r *orgRoleResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	_, role, err := parseOrgRoleResourceId(resource.Id.Resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	assignmentOptions := []ent.EntitlementOption{
+		ent.WithGrantableTo(resourceTypeUser),
+		ent.WithDisplayName(fmt.Sprintf("%s Role", resource.DisplayName)),
+		ent.WithDescription(fmt.Sprintf("%s role in the Xero organization", resource.DisplayName)),
+	}
+
+	rv := []*v2.Entitlement{
+		ent.NewAssignmentEntitlement(resource, role.id, assignmentOptions...),
+	}
+
+	return rv, "", nil, nil
+}
+
+func (r *orgRoleResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	orgId, role, err := parseOrgRoleResourceId(resource.Id.Resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	_, users, rateLimit, err := r.snapshot.orgUsers(ctx, orgId)
+	if annos, ok := deferPage(ctx, err); ok {
+		return nil, retryToken, annos, nil
+	}
+
+	annos := rateLimitAnnotations(rateLimit)
+	if skipTenant(ctx, err, zap.String("org_id", orgId)) {
+		return nil, "", annos, nil
+	}
+	if err != nil {
+		return nil, "", annos, fmt.Errorf("xero-connector: failed to list users with role %s: %w", resource.DisplayName, err)
+	}
+
+	var rv []*v2.Grant
+	for _, user := range users {
+		if userRole, _ := roleByValue(user.Role); userRole.id != role.id {
+			continue
+		}
+
+		rv = append(rv, grant.NewGrant(
+			resource,
+			role.id,
+			&v2.ResourceId{
+				ResourceType: resourceTypeUser.Id,
+				Resource:     user.Id,
+			},
+		))
+	}
+
+	return rv, "", annos, nil
+}
+
+func orgRoleBuilder(client *xero.Client, snapshot *snapshot) *orgRoleResourceType {
+	return &orgRoleResourceType{
+		resourceType: resourceTypeOrgRole,
+		client:       client,
+		snapshot:     snapshot,
+	}
+}
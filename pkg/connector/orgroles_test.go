@@ -0,0 +1,101 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-xero/pkg/xero"
+	"github.com/conductorone/baton-xero/pkg/xero/xerotest"
+)
+
+func orgRoleGrants(t *testing.T, x *Xero, orgId, roleId string) []*v2.Grant {
+	t.Helper()
+
+	role, err := orgRoleResource(context.Background(), &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: orgId}, roleById(roleId))
+	if err != nil {
+		t.Fatalf("failed to create role %s of org %s: %v", roleId, orgId, err)
+	}
+
+	r := orgRoleBuilder(x.client, x.snapshot)
+	grants, _ := listAll(t, func(ctx context.Context, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+		return r.Grants(ctx, role, pToken)
+	})
+
+	return grants
+}
+
+func TestOrgRoleList(t *testing.T) {
+	x, _ := newTestConnector(t, nil, testTenants()...)
+
+	roles, _ := listChildren(t, orgRoleBuilder(x.client, x.snapshot).List, orgAcme)
+
+	assertIds(t, resourceIds(roles),
+		orgRoleResourceId(orgAcme, readOnly),
+		orgRoleResourceId(orgAcme, invoiceOnly),
+		orgRoleResourceId(orgAcme, standard),
+		orgRoleResourceId(orgAcme, financialAdvisor),
+		orgRoleResourceId(orgAcme, managedClient),
+		orgRoleResourceId(orgAcme, cashbookClient),
+		orgRoleResourceId(orgAcme, unknownRole),
+	)
+
+	if roles[3].DisplayName != "Financial Adviser" {
+		t.Fatalf("got display name %q", roles[3].DisplayName)
+	}
+
+	topLevel, _, _, err := orgRoleBuilder(x.client, x.snapshot).List(context.Background(), nil, &pagination.Token{})
+	if err != nil || len(topLevel) != 0 {
+		t.Fatalf("got roles %v and error %v without a parent org", topLevel, err)
+	}
+}
+
+func TestOrgRoleGrants(t *testing.T) {
+	x, _ := newTestConnector(t, nil, testTenants()...)
+
+	// the role is only granted to the users of its org
+	assertIds(t, grantPrincipals(orgRoleGrants(t, x, orgDemo, readOnly)), userBob)
+	assertIds(t, grantPrincipals(orgRoleGrants(t, x, orgAcme, readOnly)), userDave)
+	assertIds(t, grantPrincipals(orgRoleGrants(t, x, orgDemo, standard)), userAlice, userCarol)
+	assertIds(t, grantPrincipals(orgRoleGrants(t, x, orgAcme, standard)))
+}
+
+func TestOrgRoleGrantsSkipForbiddenTenant(t *testing.T) {
+	x, srv := newTestConnector(t, nil, testTenants()...)
+	srv.Inject(xero.UsersEndpoint, xerotest.Fault{StatusCode: http.StatusForbidden})
+
+	assertIds(t, grantPrincipals(orgRoleGrants(t, x, orgDemo, standard)))
+}
+
+func TestParseOrgRoleResourceId(t *testing.T) {
+	tests := []struct {
+		id      string
+		wantOrg string
+		want    string
+		wantErr bool
+	}{
+		{id: orgRoleResourceId(orgDemo, standard), wantOrg: orgDemo, want: standard},
+		{id: orgRoleResourceId(orgDemo, "payrolladmin"), wantOrg: orgDemo, want: "payrolladmin"},
+		{id: standard, wantErr: true},
+		{id: orgDemo + ":", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			orgId, role, err := parseOrgRoleResourceId(tt.id)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsed invalid id %q", tt.id)
+				}
+				return
+			}
+
+			if err != nil || orgId != tt.wantOrg || role.id != tt.want {
+				t.Fatalf("got org %q role %q error %v, want org %q role %q", orgId, role.id, err, tt.wantOrg, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package connector
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
@@ -12,6 +13,7 @@ import (
 	"github.com/conductorone/baton-sdk/pkg/types/grant"
 	"github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/conductorone/baton-xero/pkg/xero"
+	"go.uber.org/zap"
 )
 
 const (
@@ -21,13 +23,130 @@ const (
 	financialAdvisor = "financialadvisor"
 	managedClient    = "managedclient"
 	cashbookClient   = "cashbookclient"
+	unknownRole      = "unknown"
 )
 
-var roles = []string{readOnly, invoiceOnly, standard, financialAdvisor, managedClient, cashbookClient}
+// xeroRole is a value of the OrganisationRole of Xero users.
+type xeroRole struct {
+	// id is the resource ID of the role, which must never change.
+	id          string
+	value       string
+	displayName string
+	description string
+}
+
+// roles is the catalog of the organisation roles documented by Xero. Roles found on users
+// but missing from the catalog are discovered when listing roles.
+var roles = []xeroRole{
+	{
+		id:          readOnly,
+		value:       "READONLY",
+		displayName: "Read Only",
+		description: "Can view the organisation's transactions, contacts and reports, but cannot create or change anything",
+	},
+	{
+		id:          invoiceOnly,
+		value:       "INVOICEONLY",
+		displayName: "Invoice Only",
+		description: "Can create, approve and send sales invoices or bills, depending on the permissions set in Xero, with no access to other areas",
+	},
+	{
+		id:          standard,
+		value:       "STANDARD",
+		displayName: "Standard",
+		description: "Can enter and reconcile transactions, manage contacts and run most reports, but cannot change the organisation settings or users",
+	},
+	{
+		// the resource ID predates the catalog and keeps the misspelling of the Xero value
+		id:          financialAdvisor,
+		value:       "FINANCIALADVISER",
+		displayName: "Financial Adviser",
+		description: "Has full access to the organisation, including its settings, users, lock dates and adviser features",
+	},
+	{
+		id:          managedClient,
+		value:       "MANAGEDCLIENT",
+		displayName: "Managed Client",
+		description: "Client of a partner plan organisation, can record and reconcile bank transactions while the partner manages the books",
+	},
+	{
+		id:          cashbookClient,
+		value:       "CASHBOOKCLIENT",
+		displayName: "Cashbook Client",
+		description: "Client of a cashbook or ledger plan organisation, can code and reconcile bank transactions only",
+	},
+	{
+		id:          unknownRole,
+		value:       "UNKNOWN",
+		displayName: "Unknown",
+		description: "Role Xero could not map to any of its documented roles",
+	},
+}
+
+// roleByValue returns the catalog role of an OrganisationRole value, or a role derived
+// from the value when it is not in the catalog. Users without a role have the unknown one.
+func roleByValue(value string) (xeroRole, bool) {
+	if value == "" {
+		value = "UNKNOWN"
+	}
+
+	for _, role := range roles {
+		if strings.EqualFold(role.value, value) {
+			return role, true
+		}
+	}
+
+	id := strings.ToLower(value)
+
+	return xeroRole{
+		id:          id,
+		value:       strings.ToUpper(value),
+		displayName: titleCase(id),
+		description: fmt.Sprintf("%s role found on Xero users, not documented by Xero", strings.ToUpper(value)),
+	}, false
+}
+
+// roleById returns the role of a role ID of the catalog or derived from a role value.
+func roleById(id string) xeroRole {
+	for _, role := range roles {
+		if role.id == id {
+			return role
+		}
+	}
+
+	role, _ := roleByValue(id)
+
+	return role
+}
+
+// rolesOf returns the catalog, followed by the roles of the users that are not in the catalog.
+func rolesOf(users []xero.User) []xeroRole {
+	discovered := make(map[string]xeroRole)
+	for _, user := range users {
+		if role, ok := roleByValue(user.Role); !ok {
+			discovered[role.id] = role
+		}
+	}
+
+	rv := append([]xeroRole{}, roles...)
+
+	ids := make([]string, 0, len(discovered))
+	for id := range discovered {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		rv = append(rv, discovered[id])
+	}
+
+	return rv
+}
 
 type roleResourceType struct {
 	resourceType *v2.ResourceType
 	client       *xero.Client
+	snapshot     *snapshot
 }
 
 func (r *roleResourceType) ResourceType(_ context.Context) *v2.ResourceType {
@@ -35,20 +154,20 @@ func (r *roleResourceType) ResourceType(_ context.Context) *v2.ResourceType {
 }
 
 // Create a new connector resource for a Xero Role.
-func roleResource(ctx context.Context, role string) (*v2.Resource, error) {
-	displayName := titleCase(role)
-
+func roleResource(ctx context.Context, role xeroRole) (*v2.Resource, error) {
 	profile := map[string]interface{}{
-		"role_name": role,
+		"role_name":         role.id,
+		"organisation_role": role.value,
 	}
 
 	resource, err := resource.NewRoleResource(
-		displayName,
+		role.displayName,
 		resourceTypeRole,
-		role,
+		role.id,
 		[]resource.RoleTraitOption{
 			resource.WithRoleProfile(profile),
 		},
+		resource.WithDescription(role.description),
 	)
 	if err != nil {
 		return nil, err
@@ -57,21 +176,56 @@ func roleResource(ctx context.Context, role string) (*v2.Resource, error) {
 	return resource, nil
 }
 
+// List returns the roles of the catalog and those discovered on the users of every tenant.
+// Roles are shared by the organizations, the organization of a role grant is the parent of
+// its user.
 func (r *roleResourceType) List(ctx context.Context, _ *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	conns, err := r.client.GetTenants(ctx)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("xero-connector: failed to list connections: %w", err)
+	}
+
+	var (
+		users     []xero.User
+		rateLimit *v2.RateLimitDescription
+	)
+
+	for _, conn := range conns {
+		tenantUsers, usersRateLimit, err := r.snapshot.users(ctx, conn.TenantId)
+		if annos, ok := deferPage(ctx, err); ok {
+			return nil, retryToken, annos, nil
+		}
+		if usersRateLimit != nil {
+			rateLimit = usersRateLimit
+		}
+		if skipTenant(ctx, err, zap.String("tenant_id", conn.TenantId)) {
+			continue
+		}
+		if err != nil {
+			return nil, "", rateLimitAnnotations(rateLimit), fmt.Errorf("xero-connector: failed to list users: %w", err)
+		}
+
+		users = append(users, tenantUsers...)
+	}
+
+	annos := rateLimitAnnotations(rateLimit)
+
 	var rv []*v2.Resource
-	for _, r := range roles {
-		rr, err := roleResource(ctx, r)
+	for _, role := range rolesOf(users) {
+		rr, err := roleResource(ctx, role)
 		if err != nil {
-			return nil, "", nil, err
+			return nil, "", annos, err
 		}
 
 		rv = append(rv, rr)
 	}
 
-	return rv, "", nil, nil
+	return rv, "", annos, nil
 }
 
 func (r *roleResourceType) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	role := roleById(resource.Id.Resource)
+
 	var rv []*v2.Entitlement
 
 	assignmentOptions := []ent.EntitlementOption{
@@ -80,22 +234,48 @@ func (r *roleResourceType) Entitlements(_ context.Context, resource *v2.Resource
 		ent.WithDescription(fmt.Sprintf("%s role in Xero organization", resource.DisplayName)),
 	}
 
-	rv = append(rv, ent.NewAssignmentEntitlement(resource, resource.Id.Resource, assignmentOptions...))
+	rv = append(rv, ent.NewAssignmentEntitlement(resource, role.id, assignmentOptions...))
 
 	return rv, "", nil, nil
 }
 
-func (r *roleResourceType) Grants(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	users, err := r.client.GetUsers(ctx, strings.ToUpper(resource.Id.Resource))
+func (r *roleResourceType) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	bag, err := tenantBag(ctx, r.client, pToken)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("xero-connector: failed to list users with role %s: %w", resource.DisplayName, err)
+		return nil, "", nil, err
+	}
+
+	if bag.Current() == nil {
+		return nil, "", nil, nil
+	}
+
+	role := roleById(resource.Id.Resource)
+
+	users, rateLimit, err := r.snapshot.users(ctx, bag.Current().ResourceID)
+	if annos, ok := deferPage(ctx, err); ok {
+		token, err := bag.Marshal()
+		return nil, token, annos, err
+	}
+
+	annos := rateLimitAnnotations(rateLimit)
+	if skipTenant(ctx, err, zap.String("tenant_id", bag.Current().ResourceID)) {
+		nextToken, err := bag.NextToken("")
+		return nil, nextToken, annos, err
+	}
+	if err != nil {
+		return nil, "", annos, fmt.Errorf("xero-connector: failed to list users with role %s: %w", resource.DisplayName, err)
 	}
 
 	var rv []*v2.Grant
 	for _, user := range users {
+		// grouping the users on their role gives every user exactly one role grant
+		if userRole, _ := roleByValue(user.Role); userRole.id != role.id {
+			continue
+		}
+
 		rv = append(rv, grant.NewGrant(
 			resource,
-			strings.ToLower(user.Role),
+			role.id,
 			&v2.ResourceId{
 				ResourceType: resourceTypeUser.Id,
 				Resource:     user.Id,
@@ -103,12 +283,18 @@ func (r *roleResourceType) Grants(ctx context.Context, resource *v2.Resource, _
 		))
 	}
 
-	return rv, "", nil, nil
+	nextToken, err := bag.NextToken("")
+	if err != nil {
+		return nil, "", annos, err
+	}
+
+	return rv, nextToken, annos, nil
 }
 
-func roleBuilder(client *xero.Client) *roleResourceType {
+func roleBuilder(client *xero.Client, snapshot *snapshot) *roleResourceType {
 	return &roleResourceType{
 		resourceType: resourceTypeRole,
 		client:       client,
+		snapshot:     snapshot,
 	}
 }
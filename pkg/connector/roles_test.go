@@ -0,0 +1,110 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-xero/pkg/xero"
+	"github.com/conductorone/baton-xero/pkg/xero/xerotest"
+)
+
+func roleGrants(t *testing.T, x *Xero, roleId string) []*v2.Grant {
+	t.Helper()
+
+	role, err := roleResource(context.Background(), roleById(roleId))
+	if err != nil {
+		t.Fatalf("failed to create role %s: %v", roleId, err)
+	}
+
+	return resourceRoleGrants(t, x, role)
+}
+
+func resourceRoleGrants(t *testing.T, x *Xero, role *v2.Resource) []*v2.Grant {
+	t.Helper()
+
+	r := roleBuilder(x.client, x.snapshot)
+	grants, _ := listAll(t, func(ctx context.Context, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+		return r.Grants(ctx, role, pToken)
+	})
+
+	return grants
+}
+
+func listRoles(t *testing.T, x *Xero) []*v2.Resource {
+	t.Helper()
+
+	roles, _ := listAll(t, func(ctx context.Context, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+		return roleBuilder(x.client, x.snapshot).List(ctx, nil, pToken)
+	})
+
+	return roles
+}
+
+func TestRoleList(t *testing.T) {
+	x, _ := newTestConnector(t, nil, testTenants()...)
+
+	roles := listRoles(t, x)
+
+	assertIds(t, resourceIds(roles), readOnly, invoiceOnly, standard, financialAdvisor, managedClient, cashbookClient, unknownRole)
+
+	if roles[3].DisplayName != "Financial Adviser" || roles[3].Description == "" {
+		t.Fatalf("unexpected display name %q or description %q", roles[3].DisplayName, roles[3].Description)
+	}
+}
+
+func TestRoleListDiscoversRoles(t *testing.T) {
+	tenants := testTenants()
+	tenants[0].Users = append(tenants[0].Users, xero.User{Id: "a0000000-0000-4000-8000-000000000005", Role: "PAYROLLADMIN"})
+	tenants[1].Users = append(tenants[1].Users, xero.User{Id: "a0000000-0000-4000-8000-000000000006", Role: "PAYROLLADMIN"})
+
+	x, _ := newTestConnector(t, nil, tenants...)
+
+	roles := listRoles(t, x)
+
+	assertIds(t, resourceIds(roles), readOnly, invoiceOnly, standard, financialAdvisor, managedClient, cashbookClient, unknownRole, "payrolladmin")
+	assertIds(t, grantPrincipals(roleGrants(t, x, "payrolladmin")), "a0000000-0000-4000-8000-000000000005", "a0000000-0000-4000-8000-000000000006")
+}
+
+func TestRoleEntitlements(t *testing.T) {
+	x, _ := newTestConnector(t, nil, testTenants()...)
+
+	role, err := roleResource(context.Background(), roleById(standard))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entitlements, _, _, err := roleBuilder(x.client, x.snapshot).Entitlements(context.Background(), role, &pagination.Token{})
+	if err != nil {
+		t.Fatalf("failed to list entitlements: %v", err)
+	}
+
+	if len(entitlements) != 1 || entitlements[0].Slug != standard {
+		t.Fatalf("unexpected entitlements %v", entitlements)
+	}
+}
+
+func TestRoleGrantsByRole(t *testing.T) {
+	x, _ := newTestConnector(t, nil, testTenants()...)
+
+	assertIds(t, grantPrincipals(roleGrants(t, x, standard)), userAlice, userCarol)
+	assertIds(t, grantPrincipals(roleGrants(t, x, readOnly)), userBob, userDave)
+	assertIds(t, grantPrincipals(roleGrants(t, x, cashbookClient)))
+	assertIds(t, grantPrincipals(roleGrants(t, x, financialAdvisor)), userErin)
+
+	for _, g := range roleGrants(t, x, standard) {
+		if g.Entitlement.Resource.Id.Resource != standard {
+			t.Fatalf("grant %s is not on the %s role", g.Id, standard)
+		}
+	}
+}
+
+func TestRoleGrantsRetriesRateLimited(t *testing.T) {
+	x, srv := newTestConnector(t, nil, testTenants()...)
+	srv.Inject(xero.UsersEndpoint, xerotest.Fault{StatusCode: http.StatusTooManyRequests, RetryAfter: 0})
+
+	assertIds(t, grantPrincipals(roleGrants(t, x, readOnly)), userBob, userDave)
+}
@@ -0,0 +1,40 @@
+package connector
+
+import (
+	"context"
+
+	"github.com/conductorone/baton-xero/pkg/xero"
+)
+
+// resourceTypeFeatures lists the parts of the Xero API each resource syncer depends on.
+// A new syncer must be added here, with a new feature in xero.PlanScopes, to request its scopes.
+var resourceTypeFeatures = map[string][]xero.Feature{
+	resourceTypeOrg.Id:        {xero.FeatureOrganisations},
+	resourceTypeUser.Id:       {xero.FeatureUsers},
+	resourceTypeRole.Id:       {xero.FeatureUsers},
+	resourceTypeOrgRole.Id:    {xero.FeatureUsers},
+	resourceTypeConnection.Id: {xero.FeatureConnections},
+}
+
+// enabledFeatures returns the parts of the Xero API used by the enabled resource syncers, without duplicates.
+func enabledFeatures() []xero.Feature {
+	ctx := context.Background()
+	seen := make(map[xero.Feature]bool)
+
+	var rv []xero.Feature
+	for _, rs := range (&Xero{}).ResourceSyncers(ctx) {
+		for _, feature := range resourceTypeFeatures[rs.ResourceType(ctx).Id] {
+			if !seen[feature] {
+				seen[feature] = true
+				rv = append(rv, feature)
+			}
+		}
+	}
+
+	return rv
+}
+
+// RequiredScopes returns the least privileged API scopes covering the enabled resource syncers.
+func RequiredScopes() []string {
+	return xero.PlanScopes(enabledFeatures()...)
+}
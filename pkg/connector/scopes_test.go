@@ -0,0 +1,17 @@
+package connector
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRequiredScopes(t *testing.T) {
+	ctx := context.Background()
+	for _, rs := range (&Xero{}).ResourceSyncers(ctx) {
+		if _, ok := resourceTypeFeatures[rs.ResourceType(ctx).Id]; !ok {
+			t.Fatalf("resource type %s has no features, its scopes are not requested", rs.ResourceType(ctx).Id)
+		}
+	}
+
+	assertIds(t, RequiredScopes(), "accounting.settings.read")
+}
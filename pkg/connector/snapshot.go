@@ -0,0 +1,212 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-xero/pkg/xero"
+)
+
+// snapshot holds the users of each tenant, fetched once per sync and shared by the syncers,
+// so that users, role grants and organization grants are derived from the same data. The
+// snapshot is reset by Validate, which the SDK calls when a sync starts or resumes. It also
+// maps organizations to their tenant.
+type snapshot struct {
+	client *xero.Client
+
+	mu         sync.Mutex
+	tenants    map[string]*tenantUsers
+	orgTenants map[string]string
+}
+
+// tenantUsers are the users of a tenant, fetched by the first syncer asking for them while
+// the others wait. done is closed once the fetch is over.
+type tenantUsers struct {
+	done  chan struct{}
+	users []xero.User
+	err   error
+}
+
+func newSnapshot(client *xero.Client) *snapshot {
+	return &snapshot{
+		client:     client,
+		tenants:    make(map[string]*tenantUsers),
+		orgTenants: make(map[string]string),
+	}
+}
+
+func (s *snapshot) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tenants = make(map[string]*tenantUsers)
+	s.orgTenants = make(map[string]string)
+}
+
+// users returns every user of the tenant, fetching them on first use. No lock is held while
+// fetching, so that syncers reading other tenants are not blocked. A failed fetch is not
+// kept, the next call tries again. The rate limit is only returned when the users were fetched.
+func (s *snapshot) users(ctx context.Context, tenantId string) ([]xero.User, *v2.RateLimitDescription, error) {
+	s.mu.Lock()
+	entry, ok := s.tenants[tenantId]
+	if !ok {
+		entry = &tenantUsers{done: make(chan struct{})}
+		s.tenants[tenantId] = entry
+	}
+	s.mu.Unlock()
+
+	if ok {
+		select {
+		case <-entry.done:
+			return entry.users, nil, entry.err
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+
+	users, rateLimit, err := s.client.GetUsers(ctx, tenantId, nil)
+	entry.users, entry.err = users, err
+
+	if err != nil {
+		s.mu.Lock()
+		if s.tenants[tenantId] == entry {
+			delete(s.tenants, tenantId)
+		}
+		s.mu.Unlock()
+	}
+
+	close(entry.done)
+
+	return users, rateLimit, err
+}
+
+func (s *snapshot) setTenant(orgId, tenantId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orgTenants[orgId] = tenantId
+}
+
+// tenantOf returns the tenant of an organization. Organizations are recorded as they are
+// listed, and looked up in every connected tenant otherwise, like when a sync is resumed.
+// The lookups are optional calls, as they only repeat what the organization listing found.
+func (s *snapshot) tenantOf(ctx context.Context, orgId string) (string, *v2.RateLimitDescription, error) {
+	s.mu.Lock()
+	tenantId, ok := s.orgTenants[orgId]
+	s.mu.Unlock()
+
+	if ok {
+		return tenantId, nil, nil
+	}
+
+	conns, err := s.client.GetTenants(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("xero-connector: failed to list connections: %w", err)
+	}
+
+	var (
+		rateLimit   *v2.RateLimitDescription
+		unavailable error
+	)
+	for _, conn := range conns {
+		var orgs []xero.Organization
+		orgs, rateLimit, err = s.client.GetOrganizations(xero.OptionalCall(ctx), conn.TenantId)
+		if tenantUnavailable(err) {
+			// the org may be in another tenant
+			unavailable = err
+			continue
+		}
+		if err != nil {
+			return "", rateLimit, fmt.Errorf("xero-connector: failed to list orgs: %w", err)
+		}
+
+		for _, org := range orgs {
+			s.setTenant(org.Id, conn.TenantId)
+			if org.Id == orgId {
+				tenantId = conn.TenantId
+			}
+		}
+
+		if tenantId != "" {
+			return tenantId, rateLimit, nil
+		}
+	}
+
+	if unavailable != nil {
+		return "", rateLimit, fmt.Errorf("xero-connector: failed to find the tenant of org %s: %w", orgId, unavailable)
+	}
+
+	return "", rateLimit, fmt.Errorf("xero-connector: no connected tenant has the org %s", orgId)
+}
+
+// orgOf returns the organization of a tenant, looked up in the tenant when it was not listed,
+// like when a sync is resumed. The lookup is an optional call.
+func (s *snapshot) orgOf(ctx context.Context, tenantId string) (string, *v2.RateLimitDescription, error) {
+	s.mu.Lock()
+	for orgId, orgTenantId := range s.orgTenants {
+		if orgTenantId == tenantId {
+			s.mu.Unlock()
+			return orgId, nil, nil
+		}
+	}
+	s.mu.Unlock()
+
+	orgs, rateLimit, err := s.client.GetOrganizations(xero.OptionalCall(ctx), tenantId)
+	if err != nil {
+		return "", rateLimit, fmt.Errorf("xero-connector: failed to list orgs: %w", err)
+	}
+
+	if len(orgs) == 0 {
+		return "", rateLimit, fmt.Errorf("xero-connector: tenant %s has no org", tenantId)
+	}
+
+	orgId := orgs[0].Id
+	s.setTenant(orgId, tenantId)
+
+	return orgId, rateLimit, nil
+}
+
+// orgUsers returns the tenant of an organization and its users.
+func (s *snapshot) orgUsers(ctx context.Context, orgId string) (string, []xero.User, *v2.RateLimitDescription, error) {
+	tenantId, rateLimit, err := s.tenantOf(ctx, orgId)
+	if err != nil {
+		return "", nil, rateLimit, err
+	}
+
+	users, usersRateLimit, err := s.users(ctx, tenantId)
+	if usersRateLimit != nil {
+		rateLimit = usersRateLimit
+	}
+	if err != nil {
+		return "", nil, rateLimit, fmt.Errorf("xero-connector: failed to list users: %w", err)
+	}
+
+	return tenantId, users, rateLimit, nil
+}
+
+// localPage returns the page of a listing served from the snapshot the token points to.
+func localPage(pToken *pagination.Token) (*xero.PageOptions, error) {
+	page := 1
+	if pToken != nil && pToken.Token != "" {
+		var err error
+		page, err = strconv.Atoi(pToken.Token)
+		if err != nil {
+			return nil, fmt.Errorf("xero-connector: invalid page token %q: %w", pToken.Token, err)
+		}
+	}
+
+	return &xero.PageOptions{Page: page, PageSize: ResourcesPageSize}, nil
+}
+
+// nextLocalPage returns the token of the given page, or no token after the last page.
+func nextLocalPage(nextPage int) string {
+	if nextPage == 0 {
+		return ""
+	}
+
+	return strconv.Itoa(nextPage)
+}
@@ -2,32 +2,50 @@ package connector
 
 import (
 	"context"
-	"fmt"
+	"strconv"
+	"strings"
 
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
 	"github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/conductorone/baton-xero/pkg/xero"
+	"go.uber.org/zap"
 )
 
 type userResourceType struct {
 	resourceType *v2.ResourceType
 	client       *xero.Client
+	snapshot     *snapshot
 }
 
 func (u *userResourceType) ResourceType(_ context.Context) *v2.ResourceType {
 	return u.resourceType
 }
 
-// Create a new connector resource for a Xero User.
-func userResource(ctx context.Context, user *xero.User) (*v2.Resource, error) {
+// userDisplayName returns the full name of the user, or the email when Xero has no name.
+func userDisplayName(user *xero.User) string {
+	name := strings.TrimSpace(strings.TrimSpace(user.FirstName) + " " + strings.TrimSpace(user.LastName))
+	if name == "" {
+		return user.Email
+	}
+
+	return name
+}
+
+// Create a new connector resource for a Xero User of an organization.
+func userResource(ctx context.Context, orgId *v2.ResourceId, user *xero.User) (*v2.Resource, error) {
 	profile := map[string]interface{}{
-		"user_id": user.Id,
+		"user_id":           user.Id,
+		"first_name":        user.FirstName,
+		"last_name":         user.LastName,
+		"organisation_role": user.Role,
+		"is_subscriber":     user.IsSubscriber,
+		"updated_at":        user.UpdatedAt.String(),
 	}
 
 	resource, err := resource.NewUserResource(
-		user.Email,
+		userDisplayName(user),
 		resourceTypeUser,
 		user.Id,
 		[]resource.UserTraitOption{
@@ -36,6 +54,7 @@ func userResource(ctx context.Context, user *xero.User) (*v2.Resource, error) {
 			resource.WithStatus(v2.UserTrait_Status_STATUS_ENABLED),
 			resource.WithUserLogin(user.Email),
 		},
+		resource.WithParentResourceID(orgId),
 	)
 	if err != nil {
 		return nil, err
@@ -44,25 +63,45 @@ func userResource(ctx context.Context, user *xero.User) (*v2.Resource, error) {
 	return resource, nil
 }
 
-func (u *userResourceType) List(ctx context.Context, _ *v2.ResourceId, _ *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
-	users, err := u.client.GetUsers(ctx, "")
+func (u *userResourceType) List(ctx context.Context, parentId *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	// users are listed under their organization
+	if parentId == nil || parentId.ResourceType != resourceTypeOrg.Id {
+		return nil, "", nil, nil
+	}
+
+	page, err := localPage(pToken)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("xero-connector: failed to list users: %w", err)
+		return nil, "", nil, err
 	}
 
+	_, users, rateLimit, err := u.snapshot.orgUsers(ctx, parentId.Resource)
+	if annos, ok := deferPage(ctx, err); ok {
+		return nil, strconv.Itoa(page.Page), annos, nil
+	}
+
+	annos := rateLimitAnnotations(rateLimit)
+	if skipTenant(ctx, err, zap.String("org_id", parentId.Resource)) {
+		return nil, "", annos, nil
+	}
+	if err != nil {
+		return nil, "", annos, err
+	}
+
+	items := xero.PageOf(users, page)
+
 	var rv []*v2.Resource
-	for _, user := range users {
+	for _, user := range items.Items {
 		userCopy := user
 
-		ur, err := userResource(ctx, &userCopy)
+		ur, err := userResource(ctx, parentId, &userCopy)
 		if err != nil {
-			return nil, "", nil, err
+			return nil, "", annos, err
 		}
 
 		rv = append(rv, ur)
 	}
 
-	return rv, "", nil, nil
+	return rv, nextLocalPage(items.NextPage), annos, nil
 }
 
 func (u *userResourceType) Entitlements(_ context.Context, _ *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
@@ -73,9 +112,10 @@ func (u *userResourceType) Grants(_ context.Context, _ *v2.Resource, _ *paginati
 	return nil, "", nil, nil
 }
 
-func userBuilder(client *xero.Client) *userResourceType {
+func userBuilder(client *xero.Client, snapshot *snapshot) *userResourceType {
 	return &userResourceType{
 		resourceType: resourceTypeUser,
 		client:       client,
+		snapshot:     snapshot,
 	}
 }
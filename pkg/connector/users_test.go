@@ -0,0 +1,246 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/conductorone/baton-xero/pkg/xero"
+	"github.com/conductorone/baton-xero/pkg/xero/xerotest"
+)
+
+func listUsers(t *testing.T, x *Xero, orgIds ...string) ([]*v2.Resource, int) {
+	t.Helper()
+
+	return listChildren(t, userBuilder(x.client, x.snapshot).List, orgIds...)
+}
+
+func TestUserListEveryTenant(t *testing.T) {
+	x, _ := newTestConnector(t, nil, testTenants()...)
+
+	users, pages := listUsers(t, x)
+
+	assertIds(t, resourceIds(users), userAlice, userBob, userCarol, userDave, userErin)
+	if pages != 2 {
+		t.Fatalf("got %d pages, want one per org", pages)
+	}
+
+	if users[0].DisplayName != "Alice Smith" {
+		t.Fatalf("got display name %q", users[0].DisplayName)
+	}
+}
+
+func TestUserProfile(t *testing.T) {
+	updatedAt, err := xero.ParseTime("/Date(1573755038314+0000)/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenants := testTenants()
+	tenants[0].Users[0].IsSubscriber = true
+	tenants[0].Users[0].UpdatedAt = updatedAt
+	tenants[0].Users[1].FirstName = ""
+	tenants[0].Users[1].LastName = ""
+
+	x, _ := newTestConnector(t, nil, tenants...)
+
+	users, _ := listUsers(t, x)
+
+	trait, err := resource.GetUserTrait(users[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"user_id":           userAlice,
+		"first_name":        "Alice",
+		"last_name":         "Smith",
+		"organisation_role": roleStandard,
+		"updated_at":        "2019-11-14T18:10:38Z",
+	}
+	for key, value := range want {
+		if got, _ := resource.GetProfileStringValue(trait.Profile, key); got != value {
+			t.Fatalf("got profile %s %q, want %q", key, got, value)
+		}
+	}
+
+	if !trait.Profile.Fields["is_subscriber"].GetBoolValue() {
+		t.Fatal("the subscriber is not flagged in the profile")
+	}
+
+	if users[1].DisplayName != "bob@example.com" {
+		t.Fatalf("got display name %q for a user without a name, want the email", users[1].DisplayName)
+	}
+}
+
+func TestUserListPaged(t *testing.T) {
+	tenants := testTenants()
+	for i := 0; i < ResourcesPageSize; i++ {
+		tenants[0].Users = append(tenants[0].Users, xero.User{
+			Id:    "c0000000-0000-4000-8000-" + padId(i),
+			Email: "user" + padId(i) + "@example.com",
+			Role:  roleReadOnly,
+		})
+	}
+
+	x, srv := newTestConnector(t, nil, tenants...)
+
+	users, pages := listUsers(t, x)
+
+	if len(users) != ResourcesPageSize+5 {
+		t.Fatalf("got %d users, want %d", len(users), ResourcesPageSize+5)
+	}
+
+	// two pages for the first org and one for the second
+	if pages != 3 {
+		t.Fatalf("got %d pages, want 3", pages)
+	}
+
+	// the pages are served from one listing of each tenant
+	if got := srv.Requests(xero.UsersEndpoint); got != 2 {
+		t.Fatalf("got %d requests to %s, want 2", got, xero.UsersEndpoint)
+	}
+}
+
+func TestUserListRenewsRejectedToken(t *testing.T) {
+	x, srv := newTestConnector(t, nil, testTenants()...)
+
+	listOrgs(t, x)
+	listUsers(t, x)
+
+	srv.RevokeTokens()
+
+	// the users are fetched again by the next sync
+	startSync(t, x)
+
+	users, _ := listUsers(t, x)
+	assertIds(t, resourceIds(users), userAlice, userBob, userCarol, userDave, userErin)
+
+	if got := srv.TokensIssued(); got != 2 {
+		t.Fatalf("got %d tokens issued, want 2", got)
+	}
+}
+
+func TestUserListUnauthorized(t *testing.T) {
+	x, srv := newTestConnector(t, nil, testTenants()...)
+	srv.Inject(xero.UsersEndpoint, xerotest.Fault{StatusCode: http.StatusUnauthorized, Times: 2})
+
+	parentId := &v2.ResourceId{ResourceType: resourceTypeOrg.Id, Resource: orgDemo}
+
+	_, _, _, err := userBuilder(x.client, x.snapshot).List(context.Background(), parentId, &pagination.Token{})
+	if err == nil {
+		t.Fatal("expected an error when the renewed token is rejected too")
+	}
+}
+
+func padId(i int) string {
+	return fmt.Sprintf("%012d", i)
+}
+
+func TestUserListSharesSnapshotWithRoles(t *testing.T) {
+	x, srv := newTestConnector(t, nil, testTenants()...)
+
+	orgs := listOrgs(t, x)
+	listUsers(t, x)
+	for _, role := range listRoles(t, x) {
+		resourceRoleGrants(t, x, role)
+	}
+	for _, org := range orgs {
+		if _, _, _, err := orgBuilder(x.client, x.snapshot).Grants(context.Background(), org, &pagination.Token{}); err != nil {
+			t.Fatalf("failed to list grants: %v", err)
+		}
+	}
+
+	// listing the orgs again within the sync keeps the users
+	listOrgs(t, x)
+	listUsers(t, x)
+
+	if got := srv.Requests(xero.UsersEndpoint); got != 2 {
+		t.Fatalf("got %d requests to %s, want one per tenant", got, xero.UsersEndpoint)
+	}
+
+	// validation, which also reads the users of each tenant, starts the next sync
+	startSync(t, x)
+	requests := srv.Requests(xero.UsersEndpoint)
+
+	listUsers(t, x)
+
+	if got := srv.Requests(xero.UsersEndpoint); got != requests+2 {
+		t.Fatalf("got %d requests to %s, want the users fetched again by the next sync", got-requests, xero.UsersEndpoint)
+	}
+}
+
+func TestUserListConcurrentSyncers(t *testing.T) {
+	x, srv := newTestConnector(t, nil, testTenants()...)
+	listOrgs(t, x)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 10; i++ {
+		for _, orgId := range []string{orgDemo, orgAcme} {
+			wg.Add(1)
+			go func(orgId string) {
+				defer wg.Done()
+
+				_, users, _, err := x.snapshot.orgUsers(context.Background(), orgId)
+				if err == nil && len(users) == 0 {
+					err = fmt.Errorf("no users for org %s", orgId)
+				}
+				errs <- err
+			}(orgId)
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("failed to read users: %v", err)
+		}
+	}
+
+	if got := srv.Requests(xero.UsersEndpoint); got != 2 {
+		t.Fatalf("got %d requests to %s, want one per tenant", got, xero.UsersEndpoint)
+	}
+}
+
+func TestUserListRetriesFailedFetch(t *testing.T) {
+	x, srv := newTestConnector(t, nil, testTenants()...)
+	listOrgs(t, x)
+	srv.Inject(xero.UsersEndpoint, xerotest.Fault{StatusCode: http.StatusServiceUnavailable})
+
+	// the offline tenant is skipped
+	users, _ := listUsers(t, x, orgDemo)
+	assertIds(t, resourceIds(users))
+
+	// and read again once it is back online
+	users, _ = listUsers(t, x, orgDemo)
+	assertIds(t, resourceIds(users), userAlice, userBob, userCarol)
+}
+
+func TestUserListOneRoleGrantPerUser(t *testing.T) {
+	tenants := testTenants()
+	tenants[0].Users = append(tenants[0].Users, xero.User{Id: "a0000000-0000-4000-8000-000000000008", Email: "norole@example.com"})
+
+	x, _ := newTestConnector(t, nil, tenants...)
+
+	users, _ := listUsers(t, x)
+
+	grantsPerUser := make(map[string]int)
+	for _, role := range listRoles(t, x) {
+		for _, principal := range grantPrincipals(resourceRoleGrants(t, x, role)) {
+			grantsPerUser[principal]++
+		}
+	}
+
+	for _, id := range resourceIds(users) {
+		if grantsPerUser[id] != 1 {
+			t.Fatalf("user %s has %d role grants, want 1", id, grantsPerUser[id])
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/conductorone/baton-xero/pkg/xero"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Kinds of problems reported by Validate.
+const (
+	findingInvalidClient      = "invalid_client"
+	findingInvalidGrant       = "invalid_grant"
+	findingTokenExpired       = "token_expired"
+	findingMissingScope       = "missing_scope"
+	findingNoConnections      = "no_connections"
+	findingTenantUnauthorized = "tenant_unauthorized"
+	findingTenantOffline      = "tenant_offline"
+	findingRateLimited        = "rate_limited"
+	findingRequestFailed      = "request_failed"
+)
+
+// codeSeverity orders the gRPC codes of findings, the most severe one is returned by Validate.
+var codeSeverity = map[codes.Code]int{
+	codes.Unauthenticated:    5,
+	codes.PermissionDenied:   4,
+	codes.FailedPrecondition: 3,
+	codes.Unavailable:        2,
+	codes.Unknown:            1,
+}
+
+type validationFinding struct {
+	kind       string
+	code       codes.Code
+	message    string
+	tenantId   string
+	tenantName string
+}
+
+func (f validationFinding) String() string {
+	if f.tenantId == "" {
+		return fmt.Sprintf("%s: %s", f.kind, f.message)
+	}
+
+	return fmt.Sprintf("%s: %s (tenant %q %s)", f.kind, f.message, f.tenantName, f.tenantId)
+}
+
+// validationReport collects the problems found while validating the connector.
+type validationReport struct {
+	findings []validationFinding
+}
+
+func (r *validationReport) add(finding validationFinding) {
+	r.findings = append(r.findings, finding)
+}
+
+// detail returns the finding as a status detail, so that callers can tell the findings apart
+// without parsing the status message.
+func (f validationFinding) detail() (*structpb.Struct, error) {
+	return structpb.NewStruct(map[string]interface{}{
+		"kind":        f.kind,
+		"code":        f.code.String(),
+		"message":     f.message,
+		"tenant_id":   f.tenantId,
+		"tenant_name": f.tenantName,
+	})
+}
+
+// fatal reports whether the finding prevents syncing every tenant, like invalid credentials or
+// missing scopes. Findings of a single tenant only affect that tenant, which the syncers skip
+// or defer.
+func (f validationFinding) fatal() bool {
+	return f.tenantId == ""
+}
+
+// err logs every finding and returns an error with the code of the most severe fatal one,
+// carrying every fatal finding in its message and as a status detail. Findings of a single
+// tenant are logged as warnings only, so that they do not stop the sync of the other tenants.
+func (r *validationReport) err(ctx context.Context) error {
+	l := ctxzap.Extract(ctx)
+
+	code := codes.Unknown
+	var (
+		messages []string
+		details  []protoiface.MessageV1
+	)
+	for _, finding := range r.findings {
+		if !finding.fatal() {
+			l.Warn(
+				"xero-connector: tenant failed validation, its resources may be skipped",
+				zap.String("kind", finding.kind),
+				zap.String("tenant_id", finding.tenantId),
+				zap.String("tenant_name", finding.tenantName),
+				zap.String("message", finding.message),
+			)
+			continue
+		}
+
+		l.Error(
+			"xero-connector: validation failed",
+			zap.String("kind", finding.kind),
+			zap.String("tenant_id", finding.tenantId),
+			zap.String("tenant_name", finding.tenantName),
+			zap.String("message", finding.message),
+		)
+
+		if codeSeverity[finding.code] > codeSeverity[code] {
+			code = finding.code
+		}
+
+		messages = append(messages, finding.String())
+
+		detail, err := finding.detail()
+		if err != nil {
+			return err
+		}
+		details = append(details, detail)
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	st, err := status.Newf(code, "xero-connector: validation failed: %s", strings.Join(messages, "; ")).WithDetails(details...)
+	if err != nil {
+		return err
+	}
+
+	return st.Err()
+}
+
+// endpointProbe issues the cheapest request of a Xero API feature for a tenant.
+type endpointProbe struct {
+	name  string
+	probe func(ctx context.Context, client *xero.Client, tenantId string) error
+}
+
+var featureProbes = map[xero.Feature]endpointProbe{
+	xero.FeatureOrganisations: {
+		name: "Organisations",
+		probe: func(ctx context.Context, client *xero.Client, tenantId string) error {
+			_, _, err := client.GetOrganizations(ctx, tenantId)
+			return err
+		},
+	},
+	xero.FeatureUsers: {
+		name: "Users",
+		probe: func(ctx context.Context, client *xero.Client, tenantId string) error {
+			_, err := client.ProbeUsers(ctx, tenantId)
+			return err
+		},
+	},
+}
+
+func (x *Xero) validate(ctx context.Context) *validationReport {
+	report := &validationReport{}
+
+	token, err := x.client.AccessToken(ctx)
+	if err != nil {
+		report.add(tokenFinding(err))
+		return report
+	}
+
+	claims, err := xero.ParseTokenClaims(token)
+	if err != nil {
+		ctxzap.Extract(ctx).Debug("xero-connector: unable to inspect access token claims", zap.Error(err))
+	} else {
+		ctxzap.Extract(ctx).Info(
+			"xero-connector: validating access token",
+			zap.String("xero_user_id", claims.XeroUserId),
+			zap.String("authentication_event_id", claims.AuthenticationEventId),
+			zap.Time("expires_at", claims.ExpiresAt()),
+			zap.Strings("scopes", claims.Scopes),
+		)
+
+		if claims.Expiry != 0 && time.Now().After(claims.ExpiresAt()) {
+			report.add(validationFinding{
+				kind:    findingTokenExpired,
+				code:    codes.Unauthenticated,
+				message: "access token has expired and no client id and secret are set to refresh it",
+			})
+			return report
+		}
+
+		if missing := xero.MissingScopes(claims.Scopes, x.scopes); len(missing) > 0 {
+			report.add(validationFinding{
+				kind:    findingMissingScope,
+				code:    codes.PermissionDenied,
+				message: fmt.Sprintf("access token is missing required scopes: %s", strings.Join(missing, ", ")),
+			})
+		}
+	}
+
+	conns, err := x.client.GetTenants(ctx)
+	if err != nil {
+		report.add(apiFinding(err, "failed to list connections"))
+		return report
+	}
+
+	if len(conns) == 0 {
+		report.add(validationFinding{
+			kind:    findingNoConnections,
+			code:    codes.FailedPrecondition,
+			message: "no connected Xero organization matches the tenant filter, authorize the app for an organization or adjust the filter",
+		})
+		return report
+	}
+
+	for _, conn := range conns {
+		for _, feature := range enabledFeatures() {
+			probe, ok := featureProbes[feature]
+			if !ok {
+				continue
+			}
+
+			err := probe.probe(xero.OptionalCall(ctx), x.client, conn.TenantId)
+
+			var budgetErr *xero.BudgetExhaustedError
+			if errors.As(err, &budgetErr) {
+				ctxzap.Extract(ctx).Warn(
+					"xero-connector: skipping endpoint check",
+					zap.String("endpoint", probe.name),
+					zap.String("tenant_id", conn.TenantId),
+					zap.String("tenant_name", conn.TenantName),
+					zap.Error(err),
+				)
+				continue
+			}
+
+			if err != nil {
+				finding := apiFinding(err, fmt.Sprintf("failed to read %s", probe.name))
+				finding.tenantId = conn.TenantId
+				finding.tenantName = conn.TenantName
+				report.add(finding)
+			}
+		}
+	}
+
+	return report
+}
+
+// tokenFinding describes a failure to obtain an access token.
+func tokenFinding(err error) validationFinding {
+	var tokenErr *xero.TokenError
+	if !errors.As(err, &tokenErr) {
+		return validationFinding{
+			kind:    findingRequestFailed,
+			code:    codes.Unavailable,
+			message: fmt.Sprintf("failed to obtain an access token: %s", err),
+		}
+	}
+
+	switch tokenErr.Code {
+	case xero.TokenErrorInvalidClient:
+		return validationFinding{
+			kind:    findingInvalidClient,
+			code:    codes.Unauthenticated,
+			message: "client id or client secret is invalid",
+		}
+	case xero.TokenErrorInvalidGrant:
+		return validationFinding{
+			kind:    findingInvalidGrant,
+			code:    codes.Unauthenticated,
+			message: "refresh token is invalid or expired, obtain a new one with the login command",
+		}
+	default:
+		return validationFinding{
+			kind:    findingRequestFailed,
+			code:    status.Code(tokenErr),
+			message: fmt.Sprintf("failed to obtain an access token: %s", tokenErr),
+		}
+	}
+}
+
+// apiFinding describes a failed Xero API request.
+func apiFinding(err error, message string) validationFinding {
+	finding := validationFinding{
+		kind:    findingRequestFailed,
+		code:    codes.Unknown,
+		message: fmt.Sprintf("%s: %s", message, err),
+	}
+
+	var apiErr *xero.APIError
+	if !errors.As(err, &apiErr) {
+		return finding
+	}
+
+	switch apiErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		finding.kind = findingTenantUnauthorized
+		finding.code = codes.PermissionDenied
+	case http.StatusServiceUnavailable:
+		finding.kind = findingTenantOffline
+		finding.code = codes.Unavailable
+	case http.StatusTooManyRequests:
+		finding.kind = findingRateLimited
+		finding.code = codes.Unavailable
+	default:
+		if apiErr.Retryable() {
+			finding.code = codes.Unavailable
+		}
+	}
+
+	return finding
+}
@@ -0,0 +1,217 @@
+package connector
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-xero/pkg/xero"
+	"github.com/conductorone/baton-xero/pkg/xero/xerotest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// testAccessToken returns an unsigned JWT with the expiry and scopes.
+func testAccessToken(t *testing.T, exp time.Time, scopes ...string) string {
+	t.Helper()
+
+	claims, err := json.Marshal(map[string]interface{}{"exp": exp.Unix(), "scope": scopes})
+	if err != nil {
+		t.Fatalf("failed to marshal claims: %v", err)
+	}
+
+	return "e30." + base64.RawURLEncoding.EncodeToString(claims) + ".c2ln"
+}
+
+// validationFindings returns the kind and tenant of each finding carried by the error.
+func validationFindings(t *testing.T, err error) [][2]string {
+	t.Helper()
+
+	st, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("validation error %v has no status", err)
+	}
+
+	var findings [][2]string
+	for _, detail := range st.Details() {
+		s, ok := detail.(*structpb.Struct)
+		if !ok {
+			t.Fatalf("unexpected detail %T", detail)
+		}
+
+		findings = append(findings, [2]string{
+			s.Fields["kind"].GetStringValue(),
+			s.Fields["tenant_id"].GetStringValue(),
+		})
+	}
+
+	return findings
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         func(opts *Options)
+		tenants      func() []*xerotest.Tenant
+		faults       map[string]xerotest.Fault
+		wantCode     codes.Code
+		wantFindings [][2]string
+	}{
+		{
+			name:     "valid",
+			wantCode: codes.OK,
+		},
+		{
+			name:         "invalid client",
+			opts:         func(opts *Options) { opts.ClientSecret = "wrong" },
+			wantCode:     codes.Unauthenticated,
+			wantFindings: [][2]string{{findingInvalidClient, ""}},
+		},
+		{
+			name:         "invalid grant",
+			opts:         func(opts *Options) { opts.RefreshToken = "revoked-refresh-token" },
+			wantCode:     codes.Unauthenticated,
+			wantFindings: [][2]string{{findingInvalidGrant, ""}},
+		},
+		{
+			name: "token expired",
+			opts: func(opts *Options) {
+				*opts = Options{Token: testAccessToken(t, time.Now().Add(-time.Hour), "accounting.settings.read"), Endpoints: opts.Endpoints}
+			},
+			wantCode:     codes.Unauthenticated,
+			wantFindings: [][2]string{{findingTokenExpired, ""}},
+		},
+		{
+			name: "missing scope",
+			opts: func(opts *Options) {
+				*opts = Options{Token: testAccessToken(t, time.Now().Add(time.Hour), "openid"), Endpoints: opts.Endpoints}
+			},
+			wantCode: codes.PermissionDenied,
+			// the token was not issued by the fake, so listing connections fails too
+			wantFindings: [][2]string{{findingMissingScope, ""}, {findingTenantUnauthorized, ""}},
+		},
+		{
+			name:         "no connections",
+			tenants:      func() []*xerotest.Tenant { return nil },
+			wantCode:     codes.FailedPrecondition,
+			wantFindings: [][2]string{{findingNoConnections, ""}},
+		},
+		{
+			name:         "tenant unauthorized",
+			faults:       map[string]xerotest.Fault{xero.UsersEndpoint: {StatusCode: http.StatusForbidden}},
+			wantCode:     codes.OK,
+			wantFindings: [][2]string{{findingTenantUnauthorized, tenantDemo}},
+		},
+		{
+			name:         "tenant offline",
+			faults:       map[string]xerotest.Fault{xero.OrgsEndpoint: {StatusCode: http.StatusServiceUnavailable, Times: 2}},
+			wantCode:     codes.OK,
+			wantFindings: [][2]string{{findingTenantOffline, tenantDemo}, {findingTenantOffline, tenantAcme}},
+		},
+		{
+			name:         "rate limited",
+			faults:       map[string]xerotest.Fault{xero.OrgsEndpoint: {StatusCode: http.StatusTooManyRequests, RetryAfter: 3600}},
+			wantCode:     codes.OK,
+			wantFindings: [][2]string{{findingRateLimited, tenantDemo}},
+		},
+		{
+			name:         "request failed",
+			faults:       map[string]xerotest.Fault{xero.UsersEndpoint: {StatusCode: http.StatusBadRequest}},
+			wantCode:     codes.OK,
+			wantFindings: [][2]string{{findingRequestFailed, tenantDemo}},
+		},
+		{
+			name: "several tenant findings",
+			faults: map[string]xerotest.Fault{
+				xero.OrgsEndpoint:  {StatusCode: http.StatusServiceUnavailable},
+				xero.UsersEndpoint: {StatusCode: http.StatusForbidden},
+			},
+			wantCode:     codes.OK,
+			wantFindings: [][2]string{{findingTenantOffline, tenantDemo}, {findingTenantUnauthorized, tenantDemo}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenants := testTenants()
+			if tt.tenants != nil {
+				tenants = tt.tenants()
+			}
+
+			x, srv := newTestConnector(t, tt.opts, tenants...)
+			for path, fault := range tt.faults {
+				srv.Inject(path, fault)
+			}
+
+			report := x.validate(context.Background())
+
+			var findings, fatal [][2]string
+			for _, finding := range report.findings {
+				findings = append(findings, [2]string{finding.kind, finding.tenantId})
+			}
+			for _, finding := range tt.wantFindings {
+				if finding[1] == "" {
+					fatal = append(fatal, finding)
+				}
+			}
+			assertFindings(t, findings, tt.wantFindings)
+
+			err := report.err(context.Background())
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("got code %s, want %s: %v", got, tt.wantCode, err)
+			}
+
+			// only the fatal findings are carried by the error
+			if err != nil {
+				assertFindings(t, validationFindings(t, err), fatal)
+			}
+		})
+	}
+}
+
+func assertFindings(t *testing.T, findings, want [][2]string) {
+	t.Helper()
+
+	if len(findings) != len(want) {
+		t.Fatalf("got findings %v, want %v", findings, want)
+	}
+	for i := range findings {
+		if findings[i] != want[i] {
+			t.Fatalf("got findings %v, want %v", findings, want)
+		}
+	}
+}
+
+func TestValidationReportMostSevereCode(t *testing.T) {
+	report := &validationReport{}
+	report.add(validationFinding{kind: findingNoConnections, code: codes.FailedPrecondition})
+	report.add(validationFinding{kind: findingMissingScope, code: codes.PermissionDenied})
+	report.add(validationFinding{kind: findingTenantOffline, code: codes.Unavailable, tenantId: tenantDemo})
+
+	err := report.err(context.Background())
+	if got := status.Code(err); got != codes.PermissionDenied {
+		t.Fatalf("got code %s, want %s: %v", got, codes.PermissionDenied, err)
+	}
+
+	assertFindings(t, validationFindings(t, err), [][2]string{{findingNoConnections, ""}, {findingMissingScope, ""}})
+}
+
+func TestValidateKeepsSyncState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	x, _ := newTestConnector(t, func(opts *Options) { opts.SyncState = xero.NewSyncState(path) }, testTenants()...)
+
+	if _, err := x.Validate(context.Background()); err != nil {
+		t.Fatalf("validation failed: %v", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("validation wrote the sync state: %v", err)
+	}
+}
@@ -3,97 +3,376 @@ package xero
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-var DefaultScopes = []string{"openid", "email", "profile", "offline_access", "accounting.settings", "accounting.transactions"}
+// tokenExpiryLeeway is how long before its expiry an access token is proactively refreshed.
+const tokenExpiryLeeway = 2 * time.Minute
 
 type Auth struct {
 	Token        string
 	RefreshToken string
 	ClientId     string
 	ClientSecret string
+	// Scopes are the API scopes requested when logging in, see PlanScopes.
+	Scopes []string
+	// Expiry is when Token expires. The zero value means the expiry is unknown.
+	Expiry time.Time
+	// Store persists the rotated refresh token between runs. It is optional.
+	Store TokenStore
+	// Endpoints are the base URLs of the Xero services, nil means the production ones.
+	Endpoints *Endpoints
+	// Secrets are read on every login and override the literal credentials above. It is optional.
+	Secrets *SecretSources
+
+	mu          sync.Mutex
+	storeLoaded bool
+	// configuredRefreshToken is the refresh token given at startup, tried if the stored one is rejected.
+	configuredRefreshToken string
+	// sourcedRefreshToken is the last refresh token read from Secrets, a different value means it was rotated.
+	sourcedRefreshToken string
 }
 
-func NewAuth(token, refreshToken, clientId, clientSecret string) *Auth {
-	return &Auth{
+func NewAuth(token, refreshToken, clientId, clientSecret string, scopes []string) *Auth {
+	auth := &Auth{
 		Token:        token,
 		RefreshToken: refreshToken,
 		ClientId:     clientId,
 		ClientSecret: clientSecret,
+		Scopes:       scopes,
 	}
+
+	// a configured access token carries its expiry, so it can be refreshed proactively as well
+	if claims, err := ParseTokenClaims(token); err == nil && claims.Expiry != 0 {
+		auth.Expiry = claims.ExpiresAt()
+	}
+
+	return auth
 }
 
 func (a *Auth) Login(ctx context.Context, httpClient *http.Client) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.login(ctx, httpClient)
+}
+
+func (a *Auth) login(ctx context.Context, httpClient *http.Client) error {
+	var (
+		res *TokenResponse
+		err error
+	)
+
+	if err := a.resolveSecrets(ctx); err != nil {
+		return err
+	}
+
+	if err := a.loadStoredToken(ctx); err != nil {
+		return err
+	}
+
 	if a.RefreshToken == "" {
 		// login to obtain new token and refresh token
-		t, rt, err := ClientCredentialsFlow(ctx, httpClient, a.ClientId, a.ClientSecret)
+		res, err = ClientCredentialsFlow(ctx, httpClient, a.Endpoints, a.ClientId, a.ClientSecret, a.Scopes)
 		if err != nil {
 			return fmt.Errorf("failed to login: %w", err)
 		}
-
-		a.Token = t
-		a.RefreshToken = rt
 	} else {
 		// use refresh token to obtain new token if present
-		t, rt, err := RefreshTokenFlow(ctx, httpClient, a.RefreshToken, a.ClientId, a.ClientSecret)
+		res, err = RefreshTokenFlow(ctx, httpClient, a.Endpoints, a.RefreshToken, a.ClientId, a.ClientSecret, a.Scopes)
+		if err != nil && a.configuredRefreshToken != "" && a.configuredRefreshToken != a.RefreshToken {
+			ctxzap.Extract(ctx).Warn("xero-connector: stored refresh token was rejected, trying the configured one", zap.Error(err))
+			res, err = RefreshTokenFlow(ctx, httpClient, a.Endpoints, a.configuredRefreshToken, a.ClientId, a.ClientSecret, a.Scopes)
+		}
 		if err != nil {
 			return fmt.Errorf("failed to refresh token: %w", err)
 		}
+	}
+
+	// the tokens are kept before persisting the refresh token, as the one just used is no
+	// longer valid: losing them would fail the run once Xero's grace period is over
+	a.Token = res.AccessToken
+	if res.RefreshToken != "" {
+		a.RefreshToken = res.RefreshToken
+	}
+	a.Expiry = time.Time{}
+	if res.ExpiresIn > 0 {
+		a.Expiry = time.Now().Add(time.Duration(res.ExpiresIn) * time.Second)
+	}
+
+	if err := a.saveRefreshToken(ctx, res.RefreshToken); err != nil {
+		ctxzap.Extract(ctx).Error(
+			"xero-connector: the rotated refresh token is only kept in memory, login again before the next run if it keeps failing",
+			zap.Error(err),
+		)
+	}
+
+	return nil
+}
+
+// resolveSecrets reads the credentials from their sources. A refresh token read from its
+// source only replaces the current one when the source changed, as the current one may have been rotated since.
+func (a *Auth) resolveSecrets(ctx context.Context) error {
+	if a.Secrets == nil {
+		return nil
+	}
+
+	if err := resolveSecret(ctx, a.Secrets.ClientId, &a.ClientId); err != nil {
+		return fmt.Errorf("failed to resolve client id: %w", err)
+	}
+
+	if err := resolveSecret(ctx, a.Secrets.ClientSecret, &a.ClientSecret); err != nil {
+		return fmt.Errorf("failed to resolve client secret: %w", err)
+	}
+
+	refreshToken := a.sourcedRefreshToken
+	if err := resolveSecret(ctx, a.Secrets.RefreshToken, &refreshToken); err != nil {
+		return fmt.Errorf("failed to resolve refresh token: %w", err)
+	}
+
+	if refreshToken != a.sourcedRefreshToken {
+		a.sourcedRefreshToken = refreshToken
+		a.RefreshToken = refreshToken
+		a.configuredRefreshToken = ""
+	}
+
+	return nil
+}
+
+// resolveAccessToken reads the access token from its source, for setups without client credentials.
+func (a *Auth) resolveAccessToken(ctx context.Context) error {
+	if a.Secrets == nil {
+		return nil
+	}
+
+	token := a.Token
+	if err := resolveSecret(ctx, a.Secrets.AccessToken, &token); err != nil {
+		return fmt.Errorf("failed to resolve access token: %w", err)
+	}
+
+	if token != a.Token {
+		a.Token = token
+		a.Expiry = time.Time{}
+		if claims, err := ParseTokenClaims(token); err == nil && claims.Expiry != 0 {
+			a.Expiry = claims.ExpiresAt()
+		}
+	}
+
+	return nil
+}
+
+// resolveSecret sets target to the value of source, unless the source is unset or empty.
+func resolveSecret(ctx context.Context, source SecretSource, target *string) error {
+	if source == nil {
+		return nil
+	}
+
+	secret, err := source.Secret(ctx)
+	if err != nil {
+		return err
+	}
+
+	if secret != "" {
+		*target = secret
+	}
+
+	return nil
+}
+
+// loadStoredToken replaces the configured refresh token with the stored one, which is newer
+// since Xero rotates the refresh token on every use. It only reads the store once.
+func (a *Auth) loadStoredToken(ctx context.Context) error {
+	if a.Store == nil || a.storeLoaded {
+		return nil
+	}
+
+	stored, err := a.Store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.storeLoaded = true
+
+	if stored == nil || stored.RefreshToken == "" {
+		return nil
+	}
+
+	a.configuredRefreshToken = a.RefreshToken
+	a.RefreshToken = stored.RefreshToken
 
-		a.Token = t
-		a.RefreshToken = rt
+	if time.Until(stored.ExpiresAt()) < refreshTokenExpiryWarning {
+		ctxzap.Extract(ctx).Warn(
+			"xero-connector: stored refresh token is about to expire from inactivity, run a sync or login again",
+			zap.Time("issued_at", stored.IssuedAt),
+			zap.Time("expires_at", stored.ExpiresAt()),
+		)
 	}
 
 	return nil
 }
 
-func ClientCredentialsFlow(ctx context.Context, httpClient *http.Client, clientId, clientSecret string) (string, string, error) {
+// saveRefreshToken persists a newly issued refresh token, because the previous one is no
+// longer valid. It is saved to the store and written back to its source if that is writable.
+func (a *Auth) saveRefreshToken(ctx context.Context, refreshToken string) error {
+	if refreshToken == "" {
+		return nil
+	}
+
+	var errs []error
+
+	if a.Store != nil {
+		err := a.Store.Save(ctx, &StoredToken{
+			RefreshToken: refreshToken,
+			IssuedAt:     time.Now().UTC(),
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to persist rotated refresh token: %w", err))
+		} else {
+			a.configuredRefreshToken = ""
+		}
+	}
+
+	if a.Secrets != nil {
+		if writer, ok := a.Secrets.RefreshToken.(SecretWriter); ok {
+			if err := writer.WriteSecret(ctx, refreshToken); err != nil {
+				errs = append(errs, fmt.Errorf("failed to write rotated refresh token back to its source: %w", err))
+			} else {
+				// reading the token back is not a rotation outside of the connector
+				a.sourcedRefreshToken = refreshToken
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// canRefresh reports whether a new access token can be obtained without user interaction.
+func (a *Auth) canRefresh() bool {
+	hasClientId := a.ClientId != "" || (a.Secrets != nil && a.Secrets.ClientId != nil)
+	hasClientSecret := a.ClientSecret != "" || (a.Secrets != nil && a.Secrets.ClientSecret != nil)
+
+	return hasClientId && hasClientSecret
+}
+
+// canRenew reports whether a rejected access token may be replaced, by logging in again or
+// by reading a rotated one from its source.
+func (a *Auth) canRenew() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.canRefresh() || (a.Secrets != nil && a.Secrets.AccessToken != nil)
+}
+
+// AccessToken returns a valid access token, logging in first if there is none yet or
+// if the current one is about to expire.
+func (a *Auth) AccessToken(ctx context.Context, httpClient *http.Client) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	expiring := !a.Expiry.IsZero() && time.Until(a.Expiry) < tokenExpiryLeeway
+	if !a.canRefresh() && (a.Token == "" || expiring) {
+		if err := a.resolveAccessToken(ctx); err != nil {
+			return "", err
+		}
+
+		expiring = !a.Expiry.IsZero() && time.Until(a.Expiry) < tokenExpiryLeeway
+	}
+
+	if a.Token == "" || (expiring && a.canRefresh()) {
+		if err := a.login(ctx, httpClient); err != nil {
+			return "", err
+		}
+	}
+
+	return a.Token, nil
+}
+
+// Refresh obtains a new access token after the API rejected staleToken. If another
+// request already replaced staleToken, the current token is returned without logging in again.
+func (a *Auth) Refresh(ctx context.Context, httpClient *http.Client, staleToken string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.Token != staleToken {
+		return a.Token, nil
+	}
+
+	if !a.canRefresh() {
+		if err := a.resolveAccessToken(ctx); err != nil {
+			return "", err
+		}
+
+		if a.Token == staleToken {
+			return "", fmt.Errorf("access token was rejected and cannot be refreshed without client id and secret")
+		}
+
+		return a.Token, nil
+	}
+
+	if err := a.login(ctx, httpClient); err != nil {
+		return "", err
+	}
+
+	return a.Token, nil
+}
+
+// ClientCredentialsFlow logs in as a custom connection. Custom connections are not
+// issued refresh tokens nor identity claims, so only the API scopes are requested.
+func ClientCredentialsFlow(ctx context.Context, httpClient *http.Client, endpoints *Endpoints, clientId, clientSecret string, scopes []string) (*TokenResponse, error) {
 	data := url.Values{}
 	data.Set("grant_type", "client_credentials")
 	data.Set("client_id", clientId)
 	data.Set("client_secret", clientSecret)
-	data.Set("scope", strings.Join(DefaultScopes, " "))
+	data.Set("scope", strings.Join(scopes, " "))
 
-	t, rt, err := exchangeToken(ctx, httpClient, &data, &Auth{
+	res, err := exchangeToken(ctx, httpClient, endpoints, &data, &Auth{
 		ClientId:     clientId,
 		ClientSecret: clientSecret,
 	})
 	if err != nil {
-		return "", "", fmt.Errorf("client_credentials flow failed: %w", err)
+		return nil, fmt.Errorf("client_credentials flow failed: %w", err)
 	}
 
-	return t, rt, nil
+	return res, nil
 }
 
-func RefreshTokenFlow(ctx context.Context, httpClient *http.Client, refreshToken, clientId, clientSecret string) (string, string, error) {
+func RefreshTokenFlow(ctx context.Context, httpClient *http.Client, endpoints *Endpoints, refreshToken, clientId, clientSecret string, scopes []string) (*TokenResponse, error) {
 	data := url.Values{}
 	data.Set("grant_type", "refresh_token")
 	data.Set("client_id", clientId)
 	data.Set("client_secret", clientSecret)
 	data.Set("refresh_token", refreshToken)
-	data.Set("scope", strings.Join(DefaultScopes, " "))
+	data.Set("scope", strings.Join(append(append([]string{}, IdentityScopes...), scopes...), " "))
 
-	t, rt, err := exchangeToken(ctx, httpClient, &data, &Auth{
+	res, err := exchangeToken(ctx, httpClient, endpoints, &data, &Auth{
 		ClientId:     clientId,
 		ClientSecret: clientSecret,
 	})
 	if err != nil {
-		return "", "", fmt.Errorf("refresh_token flow failed: %w", err)
+		return nil, fmt.Errorf("refresh_token flow failed: %w", err)
 	}
 
-	return t, rt, nil
+	return res, nil
 }
 
-func exchangeToken(ctx context.Context, httpClient *http.Client, data *url.Values, auth *Auth) (string, string, error) {
-	baseUrl := &url.URL{Scheme: "https", Host: IdentityBase, Path: ExchangeTokenEndpoint}
+func exchangeToken(ctx context.Context, httpClient *http.Client, endpoints *Endpoints, data *url.Values, auth *Auth) (*TokenResponse, error) {
+	if endpoints == nil {
+		endpoints = DefaultEndpoints()
+	}
+
+	baseUrl := joinPath(endpoints.Identity, ExchangeTokenEndpoint)
 
 	req, err := http.NewRequestWithContext(
 		ctx,
@@ -102,93 +381,77 @@ func exchangeToken(ctx context.Context, httpClient *http.Client, data *url.Value
 		strings.NewReader(data.Encode()),
 	)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.SetBasicAuth(auth.ClientId, auth.ClientSecret)
+	// apps using PKCE without a client secret identify themselves with client_id only
+	if auth.ClientSecret != "" {
+		req.SetBasicAuth(auth.ClientId, auth.ClientSecret)
+	}
 
 	rawResponse, err := httpClient.Do(req)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
 	defer rawResponse.Body.Close()
 
 	if rawResponse.StatusCode >= 300 {
-		body := ""
+		tokenErr := &TokenError{StatusCode: rawResponse.StatusCode}
 		if rawResponse.Body != nil {
 			b, _ := io.ReadAll(rawResponse.Body)
-			if b != nil {
-				body = string(b)
+			if json.Unmarshal(b, tokenErr) != nil || tokenErr.Code == "" {
+				tokenErr.Description = string(b)
 			}
 		}
-		if body == "" {
-			body = "no error body"
+		if tokenErr.Code == "" && tokenErr.Description == "" {
+			tokenErr.Description = "no error body"
 		}
-		return "", "", status.Error(codes.Code(rawResponse.StatusCode), fmt.Sprintf("Request failed: %s", body))
+		return nil, tokenErr
 	}
 
 	var res TokenResponse
 
 	if err := json.NewDecoder(rawResponse.Body).Decode(&res); err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	return res.AccessToken, res.RefreshToken, nil
-}
-
-type Connection struct {
-	Id         string `json:"id"`
-	TenantId   string `json:"tenantId"`
-	TenantName string `json:"tenantName"`
+	return &res, nil
 }
 
-func GetTenant(ctx context.Context, httpClient *http.Client, token string) (string, error) {
-	conns, err := getConnections(ctx, httpClient, token)
-	if err != nil {
-		return "", err
-	}
-
-	if len(conns) == 0 {
-		return "", fmt.Errorf("no connections found")
-	}
+// OAuth error codes returned by the identity server.
+const (
+	TokenErrorInvalidClient = "invalid_client"
+	TokenErrorInvalidGrant  = "invalid_grant"
+)
 
-	return conns[0].TenantId, nil
+// TokenError is returned when the identity server rejects a token request, e.g. with
+// invalid_client for wrong client credentials or invalid_grant for an expired refresh token.
+type TokenError struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
 }
 
-func getConnections(ctx context.Context, httpClient *http.Client, token string) ([]Connection, error) {
-	baseUrl := &url.URL{Scheme: "https", Host: ApiBase, Path: ConnectionsEndpoint}
-
-	req, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodGet,
-		baseUrl.String(),
-		nil,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("content-type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-
-	rawResponse, err := httpClient.Do(req)
-	if err != nil {
-		return nil, err
+func (e *TokenError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("token request failed with status %d: %s", e.StatusCode, e.Description)
 	}
 
-	defer rawResponse.Body.Close()
-
-	if rawResponse.StatusCode >= 300 {
-		return nil, status.Error(codes.Code(rawResponse.StatusCode), "Request failed")
+	if e.Description == "" {
+		return fmt.Sprintf("token request failed with status %d: %s", e.StatusCode, e.Code)
 	}
 
-	var res []Connection
+	return fmt.Sprintf("token request failed with status %d: %s: %s", e.StatusCode, e.Code, e.Description)
+}
 
-	if err := json.NewDecoder(rawResponse.Body).Decode(&res); err != nil {
-		return nil, err
+// GRPCStatus maps the rejection to a gRPC status, so that callers can use status.Code on wrapped token errors.
+func (e *TokenError) GRPCStatus() *status.Status {
+	code := codes.Unauthenticated
+	if e.StatusCode >= http.StatusInternalServerError {
+		code = codes.Unavailable
 	}
 
-	return res, nil
+	return status.New(code, e.Error())
 }
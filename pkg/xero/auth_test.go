@@ -0,0 +1,128 @@
+package xero
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newIdentityServer returns a fake identity server rotating the refresh token on every use.
+func newIdentityServer(t *testing.T) (*Endpoints, *[]url.Values) {
+	t.Helper()
+
+	var requests []url.Values
+	mux := http.NewServeMux()
+	mux.HandleFunc(ExchangeTokenEndpoint, func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, r.PostForm)
+
+		_ = json.NewEncoder(w).Encode(TokenResponse{
+			AccessToken:  "access-token-" + r.PostForm.Get("refresh_token"),
+			RefreshToken: "rotated-" + r.PostForm.Get("refresh_token"),
+			ExpiresIn:    1800,
+		})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoints := DefaultEndpoints()
+	endpoints.Identity = u
+
+	return endpoints, &requests
+}
+
+type failingTokenStore struct{}
+
+func (failingTokenStore) Load(context.Context) (*StoredToken, error) {
+	return nil, nil
+}
+
+func (failingTokenStore) Save(context.Context, *StoredToken) error {
+	return errors.New("read-only file system")
+}
+
+func TestLoginKeepsRotatedTokenWhenSaveFails(t *testing.T) {
+	endpoints, requests := newIdentityServer(t)
+
+	auth := NewAuth("", "refresh-token", "client-id", "client-secret", nil)
+	auth.Endpoints = endpoints
+	auth.Store = failingTokenStore{}
+
+	if err := auth.Login(context.Background(), http.DefaultClient); err != nil {
+		t.Fatalf("failed to login: %v", err)
+	}
+
+	if auth.Token != "access-token-refresh-token" || auth.RefreshToken != "rotated-refresh-token" || auth.Expiry.IsZero() {
+		t.Fatalf("got token %q, refresh token %q and expiry %s, want the rotated tokens", auth.Token, auth.RefreshToken, auth.Expiry)
+	}
+
+	// the next login uses the rotated refresh token
+	if err := auth.Login(context.Background(), http.DefaultClient); err != nil {
+		t.Fatalf("failed to login again: %v", err)
+	}
+
+	if got := (*requests)[1].Get("refresh_token"); got != "rotated-refresh-token" {
+		t.Fatalf("logged in again with refresh token %q, want the rotated one", got)
+	}
+}
+
+func TestLoginWritesRotatedTokenToFileSource(t *testing.T) {
+	ctx := context.Background()
+	endpoints, requests := newIdentityServer(t)
+
+	source := &FileSecret{Path: filepath.Join(t.TempDir(), "refresh-token")}
+	if err := source.WriteSecret(ctx, "refresh-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	newAuth := func() *Auth {
+		auth := NewAuth("", "", "client-id", "client-secret", nil)
+		auth.Endpoints = endpoints
+		auth.Secrets = &SecretSources{RefreshToken: source}
+		return auth
+	}
+
+	auth := newAuth()
+	if err := auth.Login(ctx, http.DefaultClient); err != nil {
+		t.Fatalf("failed to login: %v", err)
+	}
+
+	if got, err := source.Secret(ctx); err != nil || got != "rotated-refresh-token" {
+		t.Fatalf("file holds refresh token %q (%v), want the rotated one", got, err)
+	}
+
+	// logging in again reads the token it wrote back, and rotates it once more
+	if err := auth.Login(ctx, http.DefaultClient); err != nil {
+		t.Fatalf("failed to login again: %v", err)
+	}
+
+	// as does the next run
+	if err := newAuth().Login(ctx, http.DefaultClient); err != nil {
+		t.Fatalf("failed to login in the next run: %v", err)
+	}
+
+	var used []string
+	for _, request := range *requests {
+		used = append(used, request.Get("refresh_token"))
+	}
+
+	want := []string{"refresh-token", "rotated-refresh-token", "rotated-rotated-refresh-token"}
+	if strings.Join(used, ",") != strings.Join(want, ",") {
+		t.Fatalf("logged in with refresh tokens %v, want %v", used, want)
+	}
+}
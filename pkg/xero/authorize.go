@@ -0,0 +1,80 @@
+package xero
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NewPKCE returns a random PKCE code verifier and its S256 code challenge.
+func NewPKCE() (string, string, error) {
+	verifier, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// NewState returns a random value binding an authorization request to its redirect.
+func NewState() (string, error) {
+	return randomString(16)
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AuthorizeURL returns the URL the user opens to authorize the app with the authorization code flow and PKCE.
+func AuthorizeURL(endpoints *Endpoints, clientId, redirectURI, state, codeChallenge string, scopes []string) string {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", clientId)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	authorizeUrl := *endpoints.Authorize
+	authorizeUrl.RawQuery = query.Encode()
+
+	return authorizeUrl.String()
+}
+
+// AuthorizationCodeFlow exchanges the code received on the redirect URI for an access and refresh token.
+func AuthorizationCodeFlow(
+	ctx context.Context,
+	httpClient *http.Client,
+	endpoints *Endpoints,
+	code, redirectURI, codeVerifier, clientId, clientSecret string,
+) (*TokenResponse, error) {
+	data := url.Values{}
+	data.Set("grant_type", "authorization_code")
+	data.Set("client_id", clientId)
+	data.Set("code", code)
+	data.Set("redirect_uri", redirectURI)
+	data.Set("code_verifier", codeVerifier)
+
+	res, err := exchangeToken(ctx, httpClient, endpoints, &data, &Auth{
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("authorization_code flow failed: %w", err)
+	}
+
+	return res, nil
+}
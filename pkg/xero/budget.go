@@ -0,0 +1,158 @@
+package xero
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// optionalReserve is the share of the daily budget kept for required calls. Optional calls
+// are refused once less than this share of the budget is left.
+const optionalReserve = 10
+
+type optionalCallKey struct{}
+
+// OptionalCall marks the requests made with the returned context as optional, so that
+// they are refused before the daily budget is spent on them.
+func OptionalCall(ctx context.Context) context.Context {
+	return context.WithValue(ctx, optionalCallKey{}, true)
+}
+
+func isOptionalCall(ctx context.Context) bool {
+	optional, _ := ctx.Value(optionalCallKey{}).(bool)
+	return optional
+}
+
+// BudgetExhaustedError is returned instead of sending a request to a tenant whose daily
+// budget is spent.
+type BudgetExhaustedError struct {
+	TenantId string
+	Used     int
+	Limit    int
+	Optional bool
+	// ResetAt is when the budget of the tenant is renewed.
+	ResetAt time.Time
+}
+
+func (e *BudgetExhaustedError) Error() string {
+	if e.Optional {
+		return fmt.Sprintf(
+			"skipped optional call, daily call budget of tenant %s is nearly spent (%d of %d calls used)",
+			e.TenantId, e.Used, e.Limit,
+		)
+	}
+
+	return fmt.Sprintf(
+		"daily call budget of tenant %s is spent (%d of %d calls used) until %s",
+		e.TenantId, e.Used, e.Limit, e.ResetAt.Format(time.RFC3339),
+	)
+}
+
+func (e *BudgetExhaustedError) GRPCStatus() *status.Status {
+	return status.New(codes.ResourceExhausted, e.Error())
+}
+
+// DailyBudget caps the calls the connector makes to each tenant per UTC day. Only the calls
+// sent by the connector are counted against the budget, as other apps connected to the
+// tenant have their own share of the Xero daily limit. The X-DayLimit-Remaining header of
+// the last response is a ceiling on the calls left, so that the Xero limit is never exceeded.
+type DailyBudget struct {
+	limit int
+	now   func() time.Time
+
+	mu      sync.Mutex
+	tenants map[string]*tenantBudget
+}
+
+// tenantBudget is the use of the budget of a tenant over a UTC day. remaining is the number
+// of calls Xero allows, as last reported and less the calls sent since, or -1 when unknown.
+type tenantBudget struct {
+	day       time.Time
+	used      int
+	remaining int
+}
+
+// NewDailyBudget returns a budget allowing limit calls per tenant and day. A limit that is
+// not positive or above the Xero daily limit means the Xero daily limit.
+func NewDailyBudget(limit int) *DailyBudget {
+	if limit <= 0 || limit > DayLimit {
+		limit = DayLimit
+	}
+
+	return &DailyBudget{
+		limit:   limit,
+		now:     time.Now,
+		tenants: make(map[string]*tenantBudget),
+	}
+}
+
+// tenant returns the budget of the tenant for the current day, starting a new one at UTC
+// midnight. The lock must be held.
+func (b *DailyBudget) tenant(tenantId string) *tenantBudget {
+	day := b.now().UTC().Truncate(24 * time.Hour)
+
+	tb, ok := b.tenants[tenantId]
+	if !ok || !tb.day.Equal(day) {
+		tb = &tenantBudget{day: day, remaining: -1}
+		b.tenants[tenantId] = tb
+	}
+
+	return tb
+}
+
+// allow returns an error if the next call to the tenant would exceed the budget, and counts
+// the call otherwise.
+func (b *DailyBudget) allow(ctx context.Context, tenantId string) error {
+	if b == nil || tenantId == "" {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tb := b.tenant(tenantId)
+	optional := isOptionalCall(ctx)
+
+	available := b.limit - tb.used
+	if tb.remaining >= 0 && tb.remaining < available {
+		available = tb.remaining
+	}
+
+	reserve := 0
+	if optional {
+		reserve = b.limit * optionalReserve / 100
+	}
+
+	if available <= reserve {
+		return &BudgetExhaustedError{
+			TenantId: tenantId,
+			Used:     b.limit - available,
+			Limit:    b.limit,
+			Optional: optional,
+			ResetAt:  tb.day.Add(24 * time.Hour),
+		}
+	}
+
+	tb.used++
+	if tb.remaining > 0 {
+		tb.remaining--
+	}
+
+	return nil
+}
+
+// record updates the calls Xero allows from the rate limit state of a response.
+func (b *DailyBudget) record(tenantId string, rl *RateLimit) {
+	if b == nil || tenantId == "" || rl == nil || rl.DayRemaining < 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tenant(tenantId).remaining = rl.DayRemaining
+}
@@ -0,0 +1,110 @@
+package xero
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestBudget(limit int, now *time.Time) *DailyBudget {
+	b := NewDailyBudget(limit)
+	b.now = func() time.Time { return *now }
+
+	return b
+}
+
+// spend makes calls to the tenant until the budget refuses one, returning the calls allowed.
+func spend(t *testing.T, ctx context.Context, b *DailyBudget, tenantId string) (int, *BudgetExhaustedError) {
+	t.Helper()
+
+	for calls := 0; calls <= DayLimit; calls++ {
+		if err := b.allow(ctx, tenantId); err != nil {
+			var budgetErr *BudgetExhaustedError
+			if !errors.As(err, &budgetErr) {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			return calls, budgetErr
+		}
+	}
+
+	t.Fatal("the budget never ran out")
+
+	return 0, nil
+}
+
+func TestDailyBudgetCountsOwnCalls(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	b := newTestBudget(10, &now)
+
+	// other apps have used most of the Xero daily limit, none of it is charged to the budget
+	b.record("tenant", &RateLimit{DayRemaining: 1000})
+
+	calls, err := spend(t, context.Background(), b, "tenant")
+	if calls != 10 {
+		t.Fatalf("got %d calls allowed, want 10", calls)
+	}
+
+	if err.Optional || err.Used != 10 || !err.ResetAt.Equal(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected error %+v", err)
+	}
+
+	// the budget of each tenant is separate
+	if calls, _ := spend(t, context.Background(), b, "other"); calls != 10 {
+		t.Fatalf("got %d calls allowed to another tenant, want 10", calls)
+	}
+}
+
+func TestDailyBudgetHeaderIsCeiling(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	b := newTestBudget(0, &now)
+
+	if err := b.allow(context.Background(), "tenant"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Xero allows fewer calls than are left of the budget
+	b.record("tenant", &RateLimit{DayRemaining: 3})
+
+	if calls, _ := spend(t, context.Background(), b, "tenant"); calls != 3 {
+		t.Fatalf("got %d calls allowed, want the 3 Xero allows", calls)
+	}
+
+	// a response without the header keeps the ceiling
+	b.record("tenant", &RateLimit{DayRemaining: -1})
+
+	if err := b.allow(context.Background(), "tenant"); err == nil {
+		t.Fatal("call allowed after Xero reported none left")
+	}
+}
+
+func TestDailyBudgetOptionalReserve(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	b := newTestBudget(100, &now)
+	optional := OptionalCall(context.Background())
+
+	calls, err := spend(t, optional, b, "tenant")
+	if calls != 90 || !err.Optional {
+		t.Fatalf("got %d optional calls allowed and error %+v, want 90 and an optional error", calls, err)
+	}
+
+	// required calls use the reserve
+	if calls, _ := spend(t, context.Background(), b, "tenant"); calls != 10 {
+		t.Fatalf("got %d required calls allowed, want the reserve of 10", calls)
+	}
+}
+
+func TestDailyBudgetRenewedDaily(t *testing.T) {
+	now := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	b := newTestBudget(5, &now)
+
+	spend(t, context.Background(), b, "tenant")
+	b.record("tenant", &RateLimit{DayRemaining: 0})
+
+	now = now.Add(2 * time.Hour)
+
+	if calls, _ := spend(t, context.Background(), b, "tenant"); calls != 5 {
+		t.Fatalf("got %d calls allowed the next day, want 5", calls)
+	}
+}
@@ -0,0 +1,358 @@
+package xero
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const redacted = "REDACTED"
+
+// redactedHeaders carry credentials and are never recorded.
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// redactedFormFields carry credentials in the bodies of token requests.
+var redactedFormFields = []string{"client_secret", "refresh_token", "code", "code_verifier"}
+
+// tokenFieldPattern matches the tokens in the bodies of token responses.
+var tokenFieldPattern = regexp.MustCompile(`"(access_token|refresh_token|id_token)"(\s*):(\s*)"[^"]*"`)
+
+// Interaction is a recorded request and the response to it.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Cassette is the recording of the requests of a sync.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Placeholder formats of the identifiers replaced in cassettes, which differ by kind so that
+// a placeholder never collides with one of another kind.
+const (
+	tenantPlaceholderFormat     = "00000000-0000-4000-8000-%012d"
+	connectionPlaceholderFormat = "00000000-0000-4000-9000-%012d"
+	authEventPlaceholderFormat  = "00000000-0000-4000-a000-%012d"
+)
+
+// redactor removes credentials from interactions and replaces tenant, connection and auth
+// event IDs with placeholders, the same ID always getting the same placeholder.
+type redactor struct {
+	ids    []idPlaceholder
+	counts map[string]int
+}
+
+type idPlaceholder struct {
+	id          string
+	pattern     *regexp.Regexp
+	placeholder string
+}
+
+func (r *redactor) addId(format, id string) {
+	if id == "" {
+		return
+	}
+
+	for _, p := range r.ids {
+		if strings.EqualFold(p.id, id) {
+			return
+		}
+	}
+
+	if r.counts == nil {
+		r.counts = make(map[string]int)
+	}
+	r.counts[format]++
+
+	r.ids = append(r.ids, idPlaceholder{
+		id:          id,
+		pattern:     regexp.MustCompile(`(?i)` + regexp.QuoteMeta(id)),
+		placeholder: fmt.Sprintf(format, r.counts[format]),
+	})
+}
+
+func (r *redactor) addTenant(tenantId string) {
+	r.addId(tenantPlaceholderFormat, tenantId)
+}
+
+// addConnectionsFrom collects the IDs of a connections response.
+func (r *redactor) addConnectionsFrom(body []byte) {
+	var conns []struct {
+		Id          string `json:"id"`
+		AuthEventId string `json:"authEventId"`
+		TenantId    string `json:"tenantId"`
+	}
+	if err := json.Unmarshal(body, &conns); err != nil {
+		return
+	}
+
+	for _, conn := range conns {
+		r.addTenant(conn.TenantId)
+		r.addId(connectionPlaceholderFormat, conn.Id)
+		r.addId(authEventPlaceholderFormat, conn.AuthEventId)
+	}
+}
+
+func (r *redactor) replaceIds(s string) string {
+	for _, p := range r.ids {
+		s = p.pattern.ReplaceAllString(s, p.placeholder)
+	}
+
+	return s
+}
+
+func (r *redactor) header(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for key, values := range h {
+		for _, value := range values {
+			out.Add(key, r.replaceIds(value))
+		}
+	}
+
+	for _, key := range redactedHeaders {
+		if out.Get(key) != "" {
+			out.Set(key, redacted)
+		}
+	}
+
+	return out
+}
+
+func (r *redactor) requestBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err == nil && form.Get("grant_type") != "" {
+		for _, field := range redactedFormFields {
+			if form.Has(field) {
+				form.Set(field, redacted)
+			}
+		}
+		return form.Encode()
+	}
+
+	return r.replaceIds(string(body))
+}
+
+func (r *redactor) responseBody(body []byte) string {
+	redactedBody := tokenFieldPattern.ReplaceAll(body, []byte(`"$1"$2:$3"`+redacted+`"`))
+	return r.replaceIds(string(redactedBody))
+}
+
+// cassetteTrailer closes the interactions of a cassette file. It is written after every
+// interaction and overwritten by the next one, so that the file is always a valid cassette.
+const cassetteTrailer = "\n  ]\n}\n"
+
+// RecordingTransport records every request and response to a cassette file, with
+// credentials redacted and tenant, connection and auth event IDs replaced with placeholders.
+type RecordingTransport struct {
+	next http.RoundTripper
+	path string
+
+	mu       sync.Mutex
+	redactor *redactor
+	file     *os.File
+	closed   bool
+}
+
+// NewRecordingTransport returns a transport sending requests with next and recording them to path.
+func NewRecordingTransport(next http.RoundTripper, path string) *RecordingTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &RecordingTransport{
+		next:     next,
+		path:     path,
+		redactor: &redactor{},
+	}
+}
+
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.redactor.addTenant(req.Header.Get("xero-tenant-id"))
+	if req.Method == http.MethodDelete && strings.Contains(req.URL.Path, ConnectionsEndpoint+"/") {
+		t.redactor.addId(connectionPlaceholderFormat, path.Base(req.URL.Path))
+	}
+	t.redactor.addConnectionsFrom(respBody)
+
+	respHeader := t.redactor.header(resp.Header)
+	// redaction changes the length of the body
+	respHeader.Del("Content-Length")
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    t.redactor.replaceIds(req.URL.String()),
+			Header: t.redactor.header(req.Header),
+			Body:   t.redactor.requestBody(reqBody),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     respHeader,
+			Body:       t.redactor.responseBody(respBody),
+		},
+	}
+
+	// the interaction is written right away, so that a failing sync is recorded too
+	if err := t.append(&interaction); err != nil {
+		return nil, fmt.Errorf("failed to write cassette: %w", err)
+	}
+
+	return resp, nil
+}
+
+// append writes the interaction at the end of the cassette file, creating it on first use.
+func (t *RecordingTransport) append(interaction *Interaction) error {
+	data, err := json.MarshalIndent(interaction, "    ", "  ")
+	if err != nil {
+		return err
+	}
+
+	if t.closed {
+		return fmt.Errorf("cassette %s is closed", t.path)
+	}
+
+	var buf bytes.Buffer
+	if t.file == nil {
+		t.file, err = os.OpenFile(t.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+
+		buf.WriteString("{\n  \"interactions\": [\n    ")
+	} else {
+		buf.WriteString(",\n    ")
+	}
+
+	buf.Write(data)
+	buf.WriteString(cassetteTrailer)
+
+	if _, err := t.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	_, err = t.file.Seek(-int64(len(cassetteTrailer)), io.SeekCurrent)
+
+	return err
+}
+
+// Close closes the cassette file, which holds every interaction recorded so far.
+func (t *RecordingTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+	if t.file == nil {
+		return nil
+	}
+
+	return t.file.Close()
+}
+
+// ReplayTransport serves the responses of a cassette instead of sending requests. A request
+// is answered with the first unused interaction with the same method, URL and tenant.
+type ReplayTransport struct {
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewReplayTransport returns a transport replaying the cassette at path.
+func NewReplayTransport(path string) (*ReplayTransport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	t := &ReplayTransport{}
+	if err := json.Unmarshal(data, &t.cassette); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+
+	t.used = make([]bool, len(t.cassette.Interactions))
+
+	return t, nil
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, interaction := range t.cassette.Interactions {
+		recorded := interaction.Request
+		if t.used[i] || recorded.Method != req.Method || recorded.URL != req.URL.String() ||
+			!strings.EqualFold(recorded.Header.Get("xero-tenant-id"), req.Header.Get("xero-tenant-id")) {
+			continue
+		}
+
+		t.used[i] = true
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        interaction.Response.Header.Clone(),
+			Body:          io.NopCloser(strings.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no recorded response for %s %s", req.Method, req.URL)
+}
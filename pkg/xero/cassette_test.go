@@ -0,0 +1,182 @@
+package xero_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/conductorone/baton-xero/pkg/xero"
+	"github.com/conductorone/baton-xero/pkg/xero/xerotest"
+)
+
+func cassetteTenants() []*xerotest.Tenant {
+	return []*xerotest.Tenant{
+		{
+			Id:           "3f2504e0-4f89-41d3-9a0c-0305e82c3301",
+			ConnectionId: "c6a1e2f0-1111-4a2b-8c3d-4e5f6a7b8c01",
+			AuthEventId:  "d7b2f3a1-2222-4b3c-9d4e-5f6a7b8c9d01",
+			Name:         "Demo Company",
+			Organisation: xero.Organization{Id: "b1a3c5e7-0000-4000-8000-000000000001", Name: "Demo Company"},
+			Users:        []xero.User{{Id: "a0000000-0000-4000-8000-000000000001", Email: "alice@example.com"}},
+		},
+		{
+			Id:           "3F2504E0-4F89-41D3-9A0C-0305E82C3302",
+			ConnectionId: "c6a1e2f0-1111-4a2b-8c3d-4e5f6a7b8c02",
+			AuthEventId:  "d7b2f3a1-2222-4b3c-9d4e-5f6a7b8c9d02",
+			Name:         "Acme Ltd",
+			Organisation: xero.Organization{Id: "b1a3c5e7-0000-4000-8000-000000000002", Name: "Acme Ltd"},
+		},
+	}
+}
+
+// syncCalls makes the calls of a sync and a revoke, returning the users of each connection.
+func syncCalls(t *testing.T, ctx context.Context, client *xero.Client) ([]xero.Connection, []int) {
+	t.Helper()
+
+	conns, err := client.GetConnections(ctx)
+	if err != nil {
+		t.Fatalf("failed to list connections: %v", err)
+	}
+
+	var users []int
+	for _, conn := range conns {
+		res, _, err := client.GetUsers(ctx, conn.TenantId, nil)
+		if err != nil {
+			t.Fatalf("failed to list users: %v", err)
+		}
+		users = append(users, len(res))
+
+		if _, _, err := client.GetOrganizations(ctx, conn.TenantId); err != nil {
+			t.Fatalf("failed to list orgs: %v", err)
+		}
+	}
+
+	if err := client.DeleteConnection(ctx, conns[0].Id); err != nil {
+		t.Fatalf("failed to delete connection: %v", err)
+	}
+
+	return conns, users
+}
+
+func newCassetteClient(t *testing.T, ctx context.Context, transport http.RoundTripper, endpoints *xero.Endpoints) *xero.Client {
+	t.Helper()
+
+	auth := xero.NewAuth("", xerotest.RefreshToken, xerotest.ClientId, xerotest.ClientSecret, nil)
+
+	client, err := xero.NewClient(ctx, &http.Client{Transport: transport}, auth, xero.WithEndpoints(endpoints))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	return client
+}
+
+func readCassette(t *testing.T, path string) (string, xero.Cassette) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read cassette: %v", err)
+	}
+
+	var cassette xero.Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		t.Fatalf("cassette is not valid: %v\n%s", err, data)
+	}
+
+	return string(data), cassette
+}
+
+func TestRecordingRedactsCredentialsAndIds(t *testing.T) {
+	ctx := context.Background()
+	tenants := cassetteTenants()
+
+	srv := xerotest.NewServer(tenants...)
+	t.Cleanup(srv.Close)
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder := xero.NewRecordingTransport(nil, path)
+
+	recorded, recordedUsers := syncCalls(t, ctx, newCassetteClient(t, ctx, recorder, srv.Endpoints()))
+
+	// the cassette is complete before it is closed, in case the sync fails
+	data, cassette := readCassette(t, path)
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("failed to close cassette: %v", err)
+	}
+
+	// token, connections, users and orgs of each tenant, delete
+	if len(cassette.Interactions) != 7 {
+		t.Fatalf("got %d interactions, want 7", len(cassette.Interactions))
+	}
+
+	secrets := []struct {
+		name  string
+		value string
+	}{
+		{name: "access token", value: "access-token-1"},
+		{name: "refresh token", value: xerotest.RefreshToken},
+		{name: "client secret", value: xerotest.ClientSecret},
+		{name: "bearer header", value: "Bearer "},
+	}
+	for i, tenant := range tenants {
+		secrets = append(secrets,
+			struct{ name, value string }{name: "tenant id " + tenant.Name, value: tenant.Id},
+			struct{ name, value string }{name: "connection id " + tenant.Name, value: recorded[i].Id},
+			struct{ name, value string }{name: "auth event id " + tenant.Name, value: tenant.AuthEventId},
+		)
+	}
+
+	for _, secret := range secrets {
+		t.Run(secret.name, func(t *testing.T) {
+			if strings.Contains(strings.ToLower(data), strings.ToLower(secret.value)) {
+				t.Fatalf("cassette contains the %s %q", secret.name, secret.value)
+			}
+		})
+	}
+
+	// the cassette replays to the same data, with placeholders in place of the ids
+	replay, err := xero.NewReplayTransport(path)
+	if err != nil {
+		t.Fatalf("failed to load cassette: %v", err)
+	}
+
+	replayed, replayedUsers := syncCalls(t, ctx, newCassetteClient(t, ctx, replay, srv.Endpoints()))
+
+	if len(replayed) != len(recorded) || replayedUsers[0] != recordedUsers[0] || replayedUsers[1] != recordedUsers[1] {
+		t.Fatalf("replayed %d connections with %v users, recorded %d with %v", len(replayed), replayedUsers, len(recorded), recordedUsers)
+	}
+
+	if replayed[0].TenantId == replayed[1].TenantId || replayed[0].Id == replayed[1].Id || replayed[0].Id == replayed[0].TenantId {
+		t.Fatalf("placeholders collide: %+v", replayed)
+	}
+}
+
+func TestRecordingAfterClose(t *testing.T) {
+	srv := xerotest.NewServer(cassetteTenants()...)
+	t.Cleanup(srv.Close)
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	recorder := xero.NewRecordingTransport(nil, path)
+	client := newCassetteClient(t, context.Background(), recorder, srv.Endpoints())
+
+	if _, err := client.GetConnections(context.Background()); err != nil {
+		t.Fatalf("failed to list connections: %v", err)
+	}
+
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("failed to close cassette: %v", err)
+	}
+
+	if _, err := client.GetConnections(context.Background()); err == nil {
+		t.Fatal("recording after closing the cassette succeeded")
+	}
+
+	if _, cassette := readCassette(t, path); len(cassette.Interactions) != 2 {
+		t.Fatalf("got %d interactions, want 2", len(cassette.Interactions))
+	}
+}
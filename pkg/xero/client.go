@@ -4,176 +4,371 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
 )
 
 const (
 	ApiBase      = "api.xero.com"
 	IdentityBase = "identity.xero.com"
+	LoginBase    = "login.xero.com"
 
 	ApiEndpoint           = "/api.xro/2.0"
 	ExchangeTokenEndpoint = "/connect/token"
+	AuthorizeEndpoint     = "/identity/connect/authorize"
 	ConnectionsEndpoint   = "/connections"
 
 	UsersEndpoint = "/Users"
 	UserEndpoint  = "/Users/%s"
 	OrgsEndpoint  = "/Organisations"
-
-	RoleFilter = "OrganisationRole"
 )
 
 type Client struct {
 	httpClient   *http.Client
 	baseUrl      *url.URL
-	token        string
-	refreshToken string
-	tenant       string
+	endpoints    *Endpoints
+	auth         *Auth
+	tenantFilter *TenantFilter
+	governor     *Governor
+	budget       *DailyBudget
+	syncState    *SyncState
 }
 
-func NewClient(ctx context.Context, httpClient *http.Client, auth *Auth) (*Client, error) {
-	// login if token is not present
-	if auth.Token == "" {
-		err := auth.Login(ctx, httpClient)
-		if err != nil {
-			return nil, fmt.Errorf("failed to login: %w", err)
+type ClientOption func(*Client)
+
+// WithEndpoints overrides the base URLs of the Xero services.
+func WithEndpoints(endpoints *Endpoints) ClientOption {
+	return func(c *Client) {
+		if endpoints != nil {
+			c.endpoints = endpoints
 		}
 	}
+}
 
-	// obtain tenant id required for all requests
-	tenantId, err := GetTenant(ctx, httpClient, auth.Token)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get tenant id: %w", err)
+// WithTenantFilter restricts the tenants returned by GetTenants.
+func WithTenantFilter(filter *TenantFilter) ClientOption {
+	return func(c *Client) {
+		c.tenantFilter = filter
 	}
+}
 
-	return &Client{
-		httpClient:   httpClient,
-		baseUrl:      &url.URL{Scheme: "https", Host: ApiBase, Path: ApiEndpoint},
-		token:        auth.Token,
-		refreshToken: auth.RefreshToken,
-		tenant:       tenantId,
-	}, nil
+// WithGovernor overrides the governor bounding the requests sent to each tenant.
+func WithGovernor(governor *Governor) ClientOption {
+	return func(c *Client) {
+		c.governor = governor
+	}
 }
 
-func (c *Client) joinURL(path string) *url.URL {
-	newURL := *c.baseUrl
-	newURL.Path += path
+// WithDailyBudget caps the calls made to each tenant per day.
+func WithDailyBudget(budget *DailyBudget) ClientOption {
+	return func(c *Client) {
+		c.budget = budget
+	}
+}
 
-	return &newURL
+// WithSyncState persists the records fetched by each sync in the state, so that later syncs
+// only fetch the records modified since.
+func WithSyncState(state *SyncState) ClientOption {
+	return func(c *Client) {
+		c.syncState = state
+	}
+}
+
+// NewClient returns a Xero client. It logs in lazily on the first request, so that
+// credential problems surface with details when the connector is validated.
+func NewClient(_ context.Context, httpClient *http.Client, auth *Auth, opts ...ClientOption) (*Client, error) {
+	client := &Client{
+		httpClient: httpClient,
+		endpoints:  DefaultEndpoints(),
+		auth:       auth,
+		governor:   DefaultGovernor(),
+		budget:     NewDailyBudget(DayLimit),
+	}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	client.baseUrl = client.endpoints.API
+	// tokens must be exchanged with the identity server matching the API
+	auth.Endpoints = client.endpoints
+
+	return client, nil
+}
+
+func (c *Client) joinURL(path string) *url.URL {
+	return joinPath(c.baseUrl, path)
 }
 
 type TokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
 }
 
 type UsersResponse struct {
 	Users []User `json:"users"`
 }
 
-// GetUsers returns all users under the team account.
-func (c *Client) GetUsers(ctx context.Context, role string) ([]User, error) {
-	var usersResponse UsersResponse
+// AccessToken returns the access token used by the client, logging in if needed.
+func (c *Client) AccessToken(ctx context.Context) (string, error) {
+	return c.auth.AccessToken(ctx, c.httpClient)
+}
 
-	var err error
-	if role == "" {
-		err = c.get(ctx, c.joinURL(UsersEndpoint), &usersResponse, nil)
-	} else {
-		err = c.get(
-			ctx,
-			c.joinURL(UsersEndpoint),
-			&usersResponse,
-			map[string]string{
-				RoleFilter: role,
-			},
-		)
+// GetUsers returns the users of the given tenant matching the query, or every user without
+// one. The Users endpoint is not paged. When the client has a sync state and there is no
+// query, only the users modified since the previous sync are fetched.
+func (c *Client) GetUsers(ctx context.Context, tenantId string, query *Query) ([]User, *v2.RateLimitDescription, error) {
+	if query != nil {
+		return c.getUsers(ctx, tenantId, query)
 	}
+
+	return fetchIncremental(
+		ctx,
+		c.syncState,
+		tenantId,
+		UsersEndpoint,
+		func(u User) string { return u.Id },
+		func(ctx context.Context) ([]User, *v2.RateLimitDescription, error) {
+			return c.getUsers(ctx, tenantId, nil)
+		},
+	)
+}
+
+func (c *Client) getUsers(ctx context.Context, tenantId string, query *Query) ([]User, *v2.RateLimitDescription, error) {
+	var usersResponse UsersResponse
+
+	rateLimit, err := c.get(ctx, c.joinURL(UsersEndpoint), tenantId, &usersResponse, query, nil)
 	if err != nil {
-		return nil, err
+		return nil, rateLimit, err
 	}
 
-	return usersResponse.Users, nil
+	return usersResponse.Users, rateLimit, nil
+}
+
+// ProbeUsers checks that the users of the tenant can be read, asking only for the users
+// modified from now on so that the response is as small as possible. The sync state is
+// neither read nor updated.
+func (c *Client) ProbeUsers(ctx context.Context, tenantId string) (*v2.RateLimitDescription, error) {
+	var usersResponse UsersResponse
+
+	return c.get(withModifiedSince(ctx, time.Now()), c.joinURL(UsersEndpoint), tenantId, &usersResponse, nil, nil)
 }
 
 type OrgResponse struct {
 	Orgs []Organization `json:"Organisations"`
 }
 
-// GetOrganizations returns all organizations available.
-func (c *Client) GetOrganizations(ctx context.Context) ([]Organization, error) {
+// GetOrganizations returns the organizations of the given tenant, the Organisations endpoint
+// is not paged.
+func (c *Client) GetOrganizations(ctx context.Context, tenantId string) ([]Organization, *v2.RateLimitDescription, error) {
 	var orgsResponse OrgResponse
 
-	err := c.get(
-		ctx,
-		c.joinURL(OrgsEndpoint),
-		&orgsResponse,
-		nil,
-	)
-
+	rateLimit, err := c.get(ctx, c.joinURL(OrgsEndpoint), tenantId, &orgsResponse, nil, nil)
 	if err != nil {
-		return nil, err
+		return nil, rateLimit, err
 	}
 
-	return orgsResponse.Orgs, nil
+	return orgsResponse.Orgs, rateLimit, nil
 }
 
-func (c *Client) get(ctx context.Context, urlAddress *url.URL, resourceResponse interface{}, filters map[string]string) error {
-	return c.doRequest(ctx, urlAddress, http.MethodGet, nil, resourceResponse, filters)
+// get fetches the records of the resource matching the query, or the page of them the
+// options select. Both are optional.
+func (c *Client) get(
+	ctx context.Context,
+	urlAddress *url.URL,
+	tenantId string,
+	resourceResponse interface{},
+	query *Query,
+	opts *PageOptions,
+) (*v2.RateLimitDescription, error) {
+	if err := query.apply(urlAddress); err != nil {
+		return nil, err
+	}
+
+	opts.apply(urlAddress)
+
+	return c.doRequest(ctx, urlAddress, http.MethodGet, tenantId, nil, resourceResponse)
 }
 
 func (c *Client) doRequest(
 	ctx context.Context,
 	urlAddress *url.URL,
 	method string,
+	tenantId string,
 	data url.Values,
 	resourceResponse interface{},
-	filters map[string]string,
-) error {
-	var body strings.Reader
+) (*v2.RateLimitDescription, error) {
+	rawResponse, err := c.send(ctx, tenantId, func() (*http.Request, error) {
+		var body strings.Reader
+
+		if data != nil {
+			encodedData := data.Encode()
+			bodyReader := strings.NewReader(encodedData)
+			body = *bodyReader
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, urlAddress.String(), &body)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("content-type", "application/json")
+		req.Header.Set("accept", "application/json")
+		req.Header.Set("xero-tenant-id", tenantId)
+		setModifiedSince(ctx, req)
 
-	if data != nil {
-		encodedData := data.Encode()
-		bodyReader := strings.NewReader(encodedData)
-		body = *bodyReader
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if filters != nil {
-		q := urlAddress.Query()
-		for k, v := range filters {
-			q.Add("where", fmt.Sprintf("%s==\"%s\"", k, v))
+	defer rawResponse.Body.Close()
 
-			urlAddress.RawQuery = q.Encode()
-		}
+	rateLimit := parseRateLimit(rawResponse).Description()
+
+	// nothing was modified since the If-Modified-Since time
+	if rawResponse.StatusCode == http.StatusNotModified {
+		return rateLimit, nil
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, urlAddress.String(), &body)
+	if rawResponse.StatusCode >= 300 {
+		return rateLimit, newAPIError(rawResponse)
+	}
+
+	if err := json.NewDecoder(rawResponse.Body).Decode(resourceResponse); err != nil {
+		return rateLimit, err
+	}
+
+	return rateLimit, nil
+}
+
+// send issues the request built by newRequest with a valid access token. A request
+// rejected with 401 Unauthorized is retried once with a refreshed access token, and a
+// request rejected with 429 Too Many Requests is retried after the delay Xero asks for.
+// Every attempt to a tenant waits for the governor and counts against the daily budget,
+// the governor slot of the returned response is released when its body is closed.
+func (c *Client) send(ctx context.Context, tenantId string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	token, err := c.auth.AccessToken(ctx, c.httpClient)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req.Header.Set("content-type", "application/json")
-	req.Header.Set("accept", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
-	req.Header.Set("xero-tenant-id", c.tenant)
+	refreshed := false
+	rateLimited := 0
+	for {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
 
-	rawResponse, err := c.httpClient.Do(req)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+		rawResponse, release, err := c.attempt(ctx, tenantId, req)
+		if err != nil {
+			return nil, err
+		}
+
+		switch rawResponse.StatusCode {
+		case http.StatusUnauthorized:
+			if refreshed || !c.auth.canRenew() {
+				return rawResponse, nil
+			}
+
+			rawResponse.Body.Close()
+			release()
+			refreshed = true
+
+			token, err = c.auth.Refresh(ctx, c.httpClient, token)
+			if err != nil {
+				return nil, err
+			}
+
+		case http.StatusTooManyRequests:
+			rateLimit := parseRateLimit(rawResponse)
+			if rateLimited >= maxRateLimitRetries || rateLimit.RetryAfter > maxRateLimitWait {
+				return rawResponse, nil
+			}
+
+			rawResponse.Body.Close()
+			release()
+			rateLimited++
+
+			ctxzap.Extract(ctx).Warn(
+				"xero-connector: rate limited, retrying later",
+				zap.String("problem", rateLimit.Problem),
+				zap.Duration("retry_after", rateLimit.RetryAfter),
+				zap.Int("attempt", rateLimited),
+			)
+
+			if err := wait(ctx, rateLimit.RetryAfter); err != nil {
+				return nil, err
+			}
+
+		default:
+			return rawResponse, nil
+		}
+	}
+}
+
+// attempt sends the request once the governor and the daily budget of the tenant allow it.
+// The returned function releases the governor slot, closing the response body releases it too.
+func (c *Client) attempt(ctx context.Context, tenantId string, req *http.Request) (*http.Response, func(), error) {
+	acquired, err := c.governor.Acquire(ctx, tenantId)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	defer rawResponse.Body.Close()
+	var once sync.Once
+	release := func() { once.Do(acquired) }
 
-	if rawResponse.StatusCode >= 300 {
-		return status.Error(codes.Code(rawResponse.StatusCode), "Request failed")
+	if err := c.budget.allow(ctx, tenantId); err != nil {
+		release()
+		return nil, nil, err
 	}
 
-	if err := json.NewDecoder(rawResponse.Body).Decode(resourceResponse); err != nil {
-		return err
+	rawResponse, err := c.httpClient.Do(req)
+	if err != nil {
+		release()
+		return nil, nil, err
 	}
 
-	return nil
+	c.budget.record(tenantId, parseRateLimit(rawResponse))
+	rawResponse.Body = &releasingBody{ReadCloser: rawResponse.Body, release: release}
+
+	return rawResponse, release, nil
+}
+
+// releasingBody releases the governor slot of a response when it is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	defer b.release()
+
+	return b.ReadCloser.Close()
+}
+
+// wait blocks for the given duration, or until the context is done.
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
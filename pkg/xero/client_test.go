@@ -0,0 +1,176 @@
+package xero
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// staticSecret is a secret source returning the same secret on every read.
+type staticSecret string
+
+func (s staticSecret) Secret(context.Context) (string, error) {
+	return string(s), nil
+}
+
+func TestClientCountsEveryAttempt(t *testing.T) {
+	tests := []struct {
+		name     string
+		rejected int
+		header   http.Header
+	}{
+		{
+			name:     "rate limited",
+			rejected: http.StatusTooManyRequests,
+			header:   http.Header{headerRetryAfter: {"0"}, headerRateLimitProblem: {"minute"}},
+		},
+		{
+			name:     "unauthorized",
+			rejected: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			requests := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests == 1 {
+					for key, values := range tt.header {
+						w.Header()[key] = values
+					}
+					w.WriteHeader(tt.rejected)
+					return
+				}
+
+				_, _ = w.Write([]byte(`{"Users": []}`))
+			}))
+			t.Cleanup(srv.Close)
+
+			api, err := url.Parse(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			endpoints := DefaultEndpoints()
+			endpoints.API = api
+
+			// the access token source hands out a new token after the first one is rejected
+			auth := NewAuth("token", "", "", "", nil)
+			auth.Secrets = &SecretSources{AccessToken: staticSecret("new-token")}
+
+			governor := NewGovernor(1, 2)
+			budget := NewDailyBudget(2)
+
+			client, err := NewClient(ctx, srv.Client(), auth, WithEndpoints(endpoints), WithGovernor(governor), WithDailyBudget(budget))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, _, err := client.GetUsers(ctx, "tenant", nil); err != nil {
+				t.Fatalf("failed to get users: %v", err)
+			}
+
+			if requests != 2 {
+				t.Fatalf("got %d requests, want the rejected one and its retry", requests)
+			}
+
+			// both attempts were counted by the governor, whose slot was released
+			if acquire(t, governor, "tenant") != nil {
+				t.Fatal("the retry was not counted in the minute window")
+			}
+
+			if len(governor.tenant("tenant").slots) != 0 {
+				t.Fatal("the governor slot was not released")
+			}
+
+			// and by the budget
+			var budgetErr *BudgetExhaustedError
+			if err := budget.allow(ctx, "tenant"); !errors.As(err, &budgetErr) {
+				t.Fatalf("got error %v, want the budget spent by both attempts", err)
+			}
+		})
+	}
+}
+
+func TestGetUsersQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     *Query
+		wantWhere string
+		wantOrder string
+		wantErr   bool
+	}{
+		{name: "no query"},
+		{
+			name:      "where and order",
+			query:     &Query{Where: And(Eq("OrganisationRole", String("ADMIN")), Eq("IsSubscriber", Bool(false))), Order: []Order{Asc("LastName")}},
+			wantWhere: `(OrganisationRole=="ADMIN" AND IsSubscriber==false)`,
+			wantOrder: "LastName",
+		},
+		{
+			name:    "invalid query",
+			query:   &Query{Where: Eq("UserID", Guid("not-a-guid"))},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			var queries []url.Values
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				queries = append(queries, r.URL.Query())
+				_, _ = w.Write([]byte(`{"Users": [{"UserID": "a"}]}`))
+			}))
+			t.Cleanup(srv.Close)
+
+			api, err := url.Parse(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			endpoints := DefaultEndpoints()
+			endpoints.API = api
+
+			path := filepath.Join(t.TempDir(), "state.json")
+			client, err := NewClient(ctx, srv.Client(), NewAuth("token", "", "", "", nil), WithEndpoints(endpoints), WithSyncState(NewSyncState(path)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			users, _, err := client.GetUsers(ctx, "tenant", tt.query)
+			if tt.wantErr {
+				if err == nil || len(queries) != 0 {
+					t.Fatalf("got error %v after %d requests, want the invalid query to fail before sending", err, len(queries))
+				}
+				return
+			}
+
+			if err != nil || len(users) != 1 || len(queries) != 1 {
+				t.Fatalf("got users %v and error %v after %d requests, want one user", users, err, len(queries))
+			}
+
+			if got := queries[0].Get("where"); got != tt.wantWhere {
+				t.Fatalf("got where %q, want %q", got, tt.wantWhere)
+			}
+
+			if got := queries[0].Get("order"); got != tt.wantOrder {
+				t.Fatalf("got order %q, want %q", got, tt.wantOrder)
+			}
+
+			// only the unfiltered users are kept in the sync state
+			if _, err := os.Stat(path); os.IsNotExist(err) != (tt.query != nil) {
+				t.Fatalf("got sync state error %v, want the state written only without a query", err)
+			}
+		})
+	}
+}
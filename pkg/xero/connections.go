@@ -0,0 +1,82 @@
+package xero
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// Connection is a tenant the app has been authorized to access.
+type Connection struct {
+	Id             string `json:"id"`
+	AuthEventId    string `json:"authEventId"`
+	TenantId       string `json:"tenantId"`
+	TenantType     string `json:"tenantType"`
+	TenantName     string `json:"tenantName"`
+	CreatedDateUtc Time   `json:"createdDateUtc"`
+	UpdatedDateUtc Time   `json:"updatedDateUtc"`
+}
+
+// GetConnections returns all tenants the app is connected to, regardless of the tenant filter.
+func (c *Client) GetConnections(ctx context.Context) ([]Connection, error) {
+	baseUrl := c.endpoints.Connections
+
+	rawResponse, err := c.send(ctx, "", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(
+			ctx,
+			http.MethodGet,
+			baseUrl.String(),
+			nil,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("content-type", "application/json")
+
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer rawResponse.Body.Close()
+
+	if rawResponse.StatusCode >= 300 {
+		return nil, newAPIError(rawResponse)
+	}
+
+	var res []Connection
+
+	if err := json.NewDecoder(rawResponse.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// DeleteConnection disconnects the app from the tenant of the given connection.
+func (c *Client) DeleteConnection(ctx context.Context, connectionId string) error {
+	baseUrl := joinPath(c.endpoints.Connections, "/"+url.PathEscape(connectionId))
+
+	rawResponse, err := c.send(ctx, "", func() (*http.Request, error) {
+		return http.NewRequestWithContext(
+			ctx,
+			http.MethodDelete,
+			baseUrl.String(),
+			nil,
+		)
+	})
+	if err != nil {
+		return err
+	}
+
+	defer rawResponse.Body.Close()
+
+	if rawResponse.StatusCode >= 300 {
+		return newAPIError(rawResponse)
+	}
+
+	return nil
+}
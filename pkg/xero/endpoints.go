@@ -0,0 +1,112 @@
+package xero
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Endpoints are the base URLs of the Xero services used by the connector. They can be
+// overridden to target a local stand-in of Xero or a rewriting egress proxy.
+type Endpoints struct {
+	// API is the base URL of the Accounting API, e.g. https://api.xero.com/api.xro/2.0.
+	API *url.URL
+	// Identity is the base URL of the identity server issuing tokens, e.g. https://identity.xero.com.
+	Identity *url.URL
+	// Connections is the URL listing the tenants connected to the app, e.g. https://api.xero.com/connections.
+	Connections *url.URL
+	// Authorize is the URL users authorize the app at, e.g. https://login.xero.com/identity/connect/authorize.
+	// It is served under the identity URL when that is overridden.
+	Authorize *url.URL
+}
+
+// DefaultEndpoints returns the endpoints of the Xero production services.
+func DefaultEndpoints() *Endpoints {
+	return &Endpoints{
+		API:         &url.URL{Scheme: "https", Host: ApiBase, Path: ApiEndpoint},
+		Identity:    &url.URL{Scheme: "https", Host: IdentityBase},
+		Connections: &url.URL{Scheme: "https", Host: ApiBase, Path: ConnectionsEndpoint},
+		Authorize:   &url.URL{Scheme: "https", Host: LoginBase, Path: AuthorizeEndpoint},
+	}
+}
+
+// ParseEndpoints returns the default endpoints with the non-empty URLs overridden.
+func ParseEndpoints(apiUrl, identityUrl, connectionsUrl string) (*Endpoints, error) {
+	endpoints := DefaultEndpoints()
+
+	overrides := []struct {
+		name   string
+		rawUrl string
+		target **url.URL
+	}{
+		{"api", apiUrl, &endpoints.API},
+		{"identity", identityUrl, &endpoints.Identity},
+		{"connections", connectionsUrl, &endpoints.Connections},
+	}
+
+	for _, o := range overrides {
+		if o.rawUrl == "" {
+			continue
+		}
+
+		u, err := parseBaseURL(o.rawUrl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s url %q: %w", o.name, o.rawUrl, err)
+		}
+
+		*o.target = u
+	}
+
+	if identityUrl != "" {
+		endpoints.Authorize = joinPath(endpoints.Identity, AuthorizeEndpoint)
+	}
+
+	return endpoints, nil
+}
+
+// parseBaseURL parses an absolute https URL. Plain http is only accepted for loopback hosts.
+func parseBaseURL(rawUrl string) (*url.URL, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("url must be absolute")
+	}
+
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if !isLoopback(u.Hostname()) {
+			return nil, fmt.Errorf("plain http is only allowed for localhost")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawQuery = ""
+	u.Fragment = ""
+
+	return u, nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// joinPath returns a copy of base with path appended.
+func joinPath(base *url.URL, path string) *url.URL {
+	newURL := *base
+	newURL.Path += path
+
+	return &newURL
+}
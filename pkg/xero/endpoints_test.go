@@ -0,0 +1,74 @@
+package xero
+
+import "testing"
+
+func TestParseEndpoints(t *testing.T) {
+	tests := []struct {
+		name          string
+		identityUrl   string
+		wantIdentity  string
+		wantAuthorize string
+		wantErr       bool
+	}{
+		{
+			name:          "default",
+			wantIdentity:  "https://identity.xero.com",
+			wantAuthorize: "https://login.xero.com/identity/connect/authorize",
+		},
+		{
+			name:          "identity override",
+			identityUrl:   "http://localhost:8080/",
+			wantIdentity:  "http://localhost:8080",
+			wantAuthorize: "http://localhost:8080/identity/connect/authorize",
+		},
+		{
+			name:          "identity proxy",
+			identityUrl:   "https://egress.example.com/xero-identity",
+			wantIdentity:  "https://egress.example.com/xero-identity",
+			wantAuthorize: "https://egress.example.com/xero-identity/identity/connect/authorize",
+		},
+		{name: "plain http", identityUrl: "http://identity.example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoints, err := ParseEndpoints("", tt.identityUrl, "")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("parsing succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse endpoints: %v", err)
+			}
+
+			if got := endpoints.Identity.String(); got != tt.wantIdentity {
+				t.Fatalf("got identity %s, want %s", got, tt.wantIdentity)
+			}
+
+			if got := endpoints.Authorize.String(); got != tt.wantAuthorize {
+				t.Fatalf("got authorize %s, want %s", got, tt.wantAuthorize)
+			}
+		})
+	}
+}
+
+func TestAuthorizeURL(t *testing.T) {
+	endpoints, err := ParseEndpoints("", "http://127.0.0.1:8080", "")
+	if err != nil {
+		t.Fatalf("failed to parse endpoints: %v", err)
+	}
+
+	got := AuthorizeURL(endpoints, "client", "http://localhost:8910/callback", "state", "challenge", []string{"openid", "email"})
+	want := "http://127.0.0.1:8080/identity/connect/authorize?client_id=client&code_challenge=challenge&code_challenge_method=S256" +
+		"&redirect_uri=http%3A%2F%2Flocalhost%3A8910%2Fcallback&response_type=code&scope=openid+email&state=state"
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	// the endpoints are not modified
+	if endpoints.Authorize.RawQuery != "" {
+		t.Fatalf("authorize endpoint has a query %q", endpoints.Authorize.RawQuery)
+	}
+}
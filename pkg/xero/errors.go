@@ -0,0 +1,163 @@
+package xero
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxErrorBodySize bounds how much of an error response is read.
+const maxErrorBodySize = 64 * 1024
+
+// APIError is a request rejected by a Xero API. It carries the details of the ApiException
+// returned by the Accounting API, or of the problem returned by the API gateway.
+type APIError struct {
+	StatusCode int
+	// ErrorNumber, Type and Message describe an ApiException, like a ValidationException.
+	ErrorNumber      int
+	Type             string
+	Message          string
+	ValidationErrors []string
+	// Title and Detail describe a gateway problem, like AuthorizationUnsuccessful.
+	Title  string
+	Detail string
+	// RateLimitProblem and RetryAfter are set when the request was rate limited.
+	RateLimitProblem string
+	RetryAfter       time.Duration
+}
+
+type apiException struct {
+	ErrorNumber int    `json:"ErrorNumber"`
+	Type        string `json:"Type"`
+	Message     string `json:"Message"`
+	Title       string `json:"Title"`
+	Detail      string `json:"Detail"`
+	Elements    []struct {
+		ValidationErrors []struct {
+			Message string `json:"Message"`
+		} `json:"ValidationErrors"`
+	} `json:"Elements"`
+}
+
+// newAPIError reads the error details from a response with a status of 300 or more.
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		rl := parseRateLimit(resp)
+		apiErr.RateLimitProblem = rl.Problem
+		apiErr.RetryAfter = rl.RetryAfter
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err != nil || len(body) == 0 {
+		return apiErr
+	}
+
+	var exception apiException
+	if err := json.Unmarshal(body, &exception); err != nil {
+		// errors like an offline organisation are reported in plain text
+		apiErr.Message = strings.TrimSpace(string(body))
+		return apiErr
+	}
+
+	apiErr.ErrorNumber = exception.ErrorNumber
+	apiErr.Type = exception.Type
+	apiErr.Message = exception.Message
+	apiErr.Title = exception.Title
+	apiErr.Detail = exception.Detail
+
+	for _, element := range exception.Elements {
+		for _, validationErr := range element.ValidationErrors {
+			apiErr.ValidationErrors = append(apiErr.ValidationErrors, validationErr.Message)
+		}
+	}
+
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "xero api request failed with %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+
+	if e.Type != "" {
+		fmt.Fprintf(&b, ": %s", e.Type)
+		if e.ErrorNumber != 0 {
+			fmt.Fprintf(&b, " (error %d)", e.ErrorNumber)
+		}
+	}
+
+	if e.Detail != "" {
+		fmt.Fprintf(&b, ": %s", e.Detail)
+	}
+
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+
+	if len(e.ValidationErrors) > 0 {
+		fmt.Fprintf(&b, ": %s", strings.Join(e.ValidationErrors, "; "))
+	}
+
+	if e.RateLimitProblem != "" {
+		fmt.Fprintf(&b, ": %s rate limit exceeded", e.RateLimitProblem)
+	}
+
+	if e.RetryAfter > 0 {
+		fmt.Fprintf(&b, ", retry after %s", e.RetryAfter)
+	}
+
+	return b.String()
+}
+
+// Code maps the HTTP status of the error to a gRPC code. A rate limited request is
+// Unavailable like an offline organisation, as both succeed when sent again later.
+func (e *APIError) Code() codes.Code {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	}
+
+	if e.StatusCode >= 500 {
+		return codes.Internal
+	}
+
+	return codes.Unknown
+}
+
+// GRPCStatus maps the error to a gRPC status, so that callers can use status.Code on wrapped API errors.
+func (e *APIError) GRPCStatus() *status.Status {
+	return status.New(e.Code(), e.Error())
+}
+
+// Retryable reports whether the same request may succeed when sent again later.
+func (e *APIError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,160 @@
+package xero
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		header     http.Header
+		body       string
+		want       APIError
+		wantText   string
+	}{
+		{
+			name:       "validation exception",
+			statusCode: http.StatusBadRequest,
+			body: `{
+				"ErrorNumber": 10,
+				"Type": "ValidationException",
+				"Message": "A validation exception occurred",
+				"Elements": [
+					{"ValidationErrors": [{"Message": "Email address must be valid."}]},
+					{"ValidationErrors": [{"Message": "First name is required."}]}
+				]
+			}`,
+			want: APIError{
+				StatusCode:       http.StatusBadRequest,
+				ErrorNumber:      10,
+				Type:             "ValidationException",
+				Message:          "A validation exception occurred",
+				ValidationErrors: []string{"Email address must be valid.", "First name is required."},
+			},
+			wantText: "xero api request failed with 400 Bad Request: ValidationException (error 10): A validation exception occurred: Email address must be valid.; First name is required.",
+		},
+		{
+			name:       "api exception",
+			statusCode: http.StatusNotFound,
+			body:       `{"ErrorNumber": 404, "Type": "NotFoundException", "Message": "The resource you're looking for cannot be found"}`,
+			want: APIError{
+				StatusCode:  http.StatusNotFound,
+				ErrorNumber: 404,
+				Type:        "NotFoundException",
+				Message:     "The resource you're looking for cannot be found",
+			},
+			wantText: "xero api request failed with 404 Not Found: NotFoundException (error 404): The resource you're looking for cannot be found",
+		},
+		{
+			name:       "gateway problem",
+			statusCode: http.StatusForbidden,
+			body:       `{"Title": "Forbidden", "Detail": "AuthenticationUnsuccessful"}`,
+			want: APIError{
+				StatusCode: http.StatusForbidden,
+				Title:      "Forbidden",
+				Detail:     "AuthenticationUnsuccessful",
+			},
+			wantText: "xero api request failed with 403 Forbidden: AuthenticationUnsuccessful",
+		},
+		{
+			name:       "offline organisation",
+			statusCode: http.StatusServiceUnavailable,
+			body:       "The Organisation is offline\n",
+			want: APIError{
+				StatusCode: http.StatusServiceUnavailable,
+				Message:    "The Organisation is offline",
+			},
+			wantText: "xero api request failed with 503 Service Unavailable: The Organisation is offline",
+		},
+		{
+			name:       "rate limited",
+			statusCode: http.StatusTooManyRequests,
+			header:     http.Header{headerRateLimitProblem: {"minute"}, headerRetryAfter: {"30"}},
+			want: APIError{
+				StatusCode:       http.StatusTooManyRequests,
+				RateLimitProblem: "minute",
+				RetryAfter:       30 * time.Second,
+			},
+			wantText: "xero api request failed with 429 Too Many Requests: minute rate limit exceeded, retry after 30s",
+		},
+		{
+			name:       "no body",
+			statusCode: http.StatusInternalServerError,
+			want:       APIError{StatusCode: http.StatusInternalServerError},
+			wantText:   "xero api request failed with 500 Internal Server Error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.header
+			if header == nil {
+				header = http.Header{}
+			}
+
+			got := newAPIError(&http.Response{
+				StatusCode: tt.statusCode,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			})
+
+			if got.StatusCode != tt.want.StatusCode || got.ErrorNumber != tt.want.ErrorNumber ||
+				got.Type != tt.want.Type || got.Message != tt.want.Message ||
+				got.Title != tt.want.Title || got.Detail != tt.want.Detail ||
+				got.RateLimitProblem != tt.want.RateLimitProblem || got.RetryAfter != tt.want.RetryAfter ||
+				strings.Join(got.ValidationErrors, "|") != strings.Join(tt.want.ValidationErrors, "|") {
+				t.Fatalf("got %+v, want %+v", *got, tt.want)
+			}
+
+			if got.Error() != tt.wantText {
+				t.Fatalf("got message %q, want %q", got.Error(), tt.wantText)
+			}
+		})
+	}
+}
+
+func TestAPIErrorCode(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       codes.Code
+		retryable  bool
+	}{
+		{http.StatusBadRequest, codes.InvalidArgument, false},
+		{http.StatusUnauthorized, codes.Unauthenticated, false},
+		{http.StatusForbidden, codes.PermissionDenied, false},
+		{http.StatusNotFound, codes.NotFound, false},
+		{http.StatusConflict, codes.AlreadyExists, false},
+		{http.StatusPreconditionFailed, codes.FailedPrecondition, false},
+		{http.StatusTooManyRequests, codes.Unavailable, true},
+		{http.StatusInternalServerError, codes.Internal, true},
+		{http.StatusNotImplemented, codes.Unimplemented, false},
+		{http.StatusBadGateway, codes.Unavailable, true},
+		{http.StatusServiceUnavailable, codes.Unavailable, true},
+		{http.StatusGatewayTimeout, codes.Unavailable, true},
+		{http.StatusTeapot, codes.Unknown, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.statusCode), func(t *testing.T) {
+			err := &APIError{StatusCode: tt.statusCode}
+
+			// wrapped errors keep their code
+			if got := status.Code(fmt.Errorf("wrapped: %w", err)); got != tt.want {
+				t.Fatalf("got code %s, want %s", got, tt.want)
+			}
+
+			if got := err.Retryable(); got != tt.retryable {
+				t.Fatalf("got retryable %t, want %t", got, tt.retryable)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package xero
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const rateWindow = time.Minute
+
+// Governor bounds the requests sent to each tenant, so that many tenants can be synced
+// in parallel without any of them exceeding the Xero concurrent and per-minute limits.
+type Governor struct {
+	concurrency int
+	perMinute   int
+
+	mu      sync.Mutex
+	tenants map[string]*tenantGovernor
+}
+
+type tenantGovernor struct {
+	slots chan struct{}
+
+	mu    sync.Mutex
+	calls []time.Time
+}
+
+// NewGovernor returns a governor allowing concurrency in-flight requests and perMinute
+// requests in any one minute window for each tenant.
+func NewGovernor(concurrency, perMinute int) *Governor {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &Governor{
+		concurrency: concurrency,
+		perMinute:   perMinute,
+		tenants:     make(map[string]*tenantGovernor),
+	}
+}
+
+// DefaultGovernor returns a governor enforcing the limits Xero applies to every tenant.
+func DefaultGovernor() *Governor {
+	return NewGovernor(ConcurrentLimit, MinuteLimit)
+}
+
+func (g *Governor) tenant(tenantId string) *tenantGovernor {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	t, ok := g.tenants[tenantId]
+	if !ok {
+		t = &tenantGovernor{slots: make(chan struct{}, g.concurrency)}
+		g.tenants[tenantId] = t
+	}
+
+	return t
+}
+
+// Acquire blocks until a request may be sent to the tenant, or the context is done.
+// The returned function must be called once the response has been consumed.
+func (g *Governor) Acquire(ctx context.Context, tenantId string) (func(), error) {
+	if g == nil || tenantId == "" {
+		return func() {}, nil
+	}
+
+	t := g.tenant(tenantId)
+
+	select {
+	case t.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	release := func() { <-t.slots }
+
+	if err := t.waitForWindow(ctx, g.perMinute); err != nil {
+		release()
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// waitForWindow blocks until fewer than perMinute requests were sent in the last minute
+// and records the request about to be sent.
+func (t *tenantGovernor) waitForWindow(ctx context.Context, perMinute int) error {
+	if perMinute <= 0 {
+		return nil
+	}
+
+	for {
+		t.mu.Lock()
+		now := time.Now()
+
+		expired := 0
+		for expired < len(t.calls) && now.Sub(t.calls[expired]) >= rateWindow {
+			expired++
+		}
+		t.calls = t.calls[expired:]
+
+		if len(t.calls) < perMinute {
+			t.calls = append(t.calls, now)
+			t.mu.Unlock()
+			return nil
+		}
+
+		delay := rateWindow - now.Sub(t.calls[0])
+		t.mu.Unlock()
+
+		if err := wait(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package xero
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// acquire returns the release function of a slot, or nil if none was free within a short while.
+func acquire(t *testing.T, g *Governor, tenantId string) func() {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	release, err := g.Acquire(ctx, tenantId)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("failed to acquire: %v", err)
+	}
+
+	return release
+}
+
+func TestGovernorConcurrency(t *testing.T) {
+	g := NewGovernor(2, 0)
+
+	first := acquire(t, g, "tenant-1")
+	second := acquire(t, g, "tenant-1")
+	if first == nil || second == nil {
+		t.Fatal("the free slots were not acquired")
+	}
+
+	if acquire(t, g, "tenant-1") != nil {
+		t.Fatal("acquired more slots than the concurrency")
+	}
+
+	// the slots are held per tenant
+	if acquire(t, g, "tenant-2") == nil {
+		t.Fatal("another tenant waited for the slots of tenant-1")
+	}
+
+	first()
+
+	if acquire(t, g, "tenant-1") == nil {
+		t.Fatal("the released slot was not acquired")
+	}
+}
+
+func TestGovernorMinuteWindow(t *testing.T) {
+	g := NewGovernor(5, 2)
+
+	for i := 0; i < 2; i++ {
+		release := acquire(t, g, "tenant-1")
+		if release == nil {
+			t.Fatalf("call %d was held back", i+1)
+		}
+
+		// releasing the slot does not give the call back to the window
+		release()
+	}
+
+	if acquire(t, g, "tenant-1") != nil {
+		t.Fatal("acquired more calls than allowed per minute")
+	}
+
+	if acquire(t, g, "tenant-2") == nil {
+		t.Fatal("another tenant waited for the window of tenant-1")
+	}
+
+	// the first call leaves the window a minute after it was made
+	tenant := g.tenant("tenant-1")
+	tenant.mu.Lock()
+	tenant.calls[0] = tenant.calls[0].Add(-rateWindow)
+	tenant.mu.Unlock()
+
+	if acquire(t, g, "tenant-1") == nil {
+		t.Fatal("the call that left the window was not given back")
+	}
+
+	if acquire(t, g, "tenant-1") != nil {
+		t.Fatal("acquired more calls than allowed per minute")
+	}
+}
+
+func TestGovernorWithoutTenant(t *testing.T) {
+	g := NewGovernor(1, 1)
+
+	for i := 0; i < 3; i++ {
+		if acquire(t, g, "") == nil {
+			t.Fatalf("call %d without a tenant was held back", i+1)
+		}
+	}
+}
@@ -1,15 +1,18 @@
 package xero
 
 type User struct {
-	Id        string `json:"UserID"`
-	Email     string `json:"EmailAddress"`
-	FirstName string `json:"FirstName"`
-	LastName  string `json:"LastName"`
-	Role      string `json:"OrganisationRole"`
+	Id           string `json:"UserID"`
+	Email        string `json:"EmailAddress"`
+	FirstName    string `json:"FirstName"`
+	LastName     string `json:"LastName"`
+	Role         string `json:"OrganisationRole"`
+	IsSubscriber bool   `json:"IsSubscriber"`
+	UpdatedAt    Time   `json:"UpdatedDateUTC"`
 }
 
 type Organization struct {
-	Id      string `json:"OrganisationID"`
-	Name    string `json:"Name"`
-	Country string `json:"CountryCode"`
+	Id        string `json:"OrganisationID"`
+	Name      string `json:"Name"`
+	Country   string `json:"CountryCode"`
+	CreatedAt Time   `json:"CreatedDateUTC"`
 }
@@ -0,0 +1,127 @@
+package xero
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+)
+
+// MaxPageSize is the largest page size accepted by Xero.
+const MaxPageSize = 1000
+
+// PageOptions selects a page of a listing with Xero's page and pageSize parameters.
+// Pages are numbered from 1.
+type PageOptions struct {
+	Page     int
+	PageSize int
+}
+
+func (o *PageOptions) apply(u *url.URL) {
+	if o == nil {
+		return
+	}
+
+	q := u.Query()
+
+	page := o.Page
+	if page < 1 {
+		page = 1
+	}
+	q.Set("page", strconv.Itoa(page))
+
+	if o.PageSize > 0 {
+		pageSize := o.PageSize
+		if pageSize > MaxPageSize {
+			pageSize = MaxPageSize
+		}
+		q.Set("pageSize", strconv.Itoa(pageSize))
+	}
+
+	u.RawQuery = q.Encode()
+}
+
+// Pagination is the metadata returned with every page of a paged endpoint.
+type Pagination struct {
+	Page      int `json:"page"`
+	PageSize  int `json:"pageSize"`
+	PageCount int `json:"pageCount"`
+	ItemCount int `json:"itemCount"`
+}
+
+// Page is a page of a listing.
+type Page[T any] struct {
+	Items []T
+	// NextPage is the number of the following page, 0 after the last page.
+	NextPage int
+}
+
+// NewPage returns a page of items with the pagination metadata of its response. Endpoints
+// that are not paged, like Users and Organisations, ignore the page parameters and return
+// no pagination metadata, so their only page holds every item.
+func NewPage[T any](items []T, meta *Pagination) *Page[T] {
+	page := &Page[T]{Items: items}
+
+	if meta != nil && meta.Page < meta.PageCount {
+		page.NextPage = meta.Page + 1
+	}
+
+	return page
+}
+
+// PageOf returns the page of items the options point to, for listings held in memory
+// like the responses of the endpoints that are not paged.
+func PageOf[T any](items []T, opts *PageOptions) *Page[T] {
+	page := opts.Page
+	if page < 1 {
+		page = 1
+	}
+
+	start := (page - 1) * opts.PageSize
+	if opts.PageSize <= 0 || start >= len(items) {
+		if page == 1 {
+			return &Page[T]{Items: items}
+		}
+
+		return &Page[T]{}
+	}
+
+	end := start + opts.PageSize
+	if end >= len(items) {
+		return &Page[T]{Items: items[start:]}
+	}
+
+	return &Page[T]{Items: items[start:end], NextPage: page + 1}
+}
+
+// FetchPages fetches every page of a paged endpoint, pageSize items at a time, and returns
+// their items with the rate limit of the last page.
+func FetchPages[T any](
+	ctx context.Context,
+	pageSize int,
+	fetch func(ctx context.Context, opts *PageOptions) (*Page[T], *v2.RateLimitDescription, error),
+) ([]T, *v2.RateLimitDescription, error) {
+	var (
+		items     []T
+		rateLimit *v2.RateLimitDescription
+	)
+
+	opts := &PageOptions{Page: 1, PageSize: pageSize}
+	for opts.Page != 0 {
+		var (
+			page *Page[T]
+			err  error
+		)
+
+		page, rateLimit, err = fetch(ctx, opts)
+		if err != nil {
+			return nil, rateLimit, err
+		}
+
+		items = append(items, page.Items...)
+		opts = &PageOptions{Page: page.NextPage, PageSize: pageSize}
+	}
+
+	return items, rateLimit, nil
+}
@@ -0,0 +1,175 @@
+package xero
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+)
+
+func TestPageOptionsApply(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *PageOptions
+		want string
+	}{
+		{name: "no options", want: "where=Name%3D%3D%22a%22"},
+		{name: "page", opts: &PageOptions{Page: 3}, want: "page=3&where=Name%3D%3D%22a%22"},
+		{name: "first page", opts: &PageOptions{PageSize: 10}, want: "page=1&pageSize=10&where=Name%3D%3D%22a%22"},
+		{name: "largest page size", opts: &PageOptions{Page: 2, PageSize: 5000}, want: "page=2&pageSize=1000&where=Name%3D%3D%22a%22"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(`https://api.xero.com/api.xro/2.0/Contacts?where=Name%3D%3D%22a%22`)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tt.opts.apply(u)
+
+			if u.RawQuery != tt.want {
+				t.Fatalf("got query %q, want %q", u.RawQuery, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	tests := []struct {
+		name string
+		meta *Pagination
+		want int
+	}{
+		{name: "not paged", want: 0},
+		{name: "first page", meta: &Pagination{Page: 1, PageSize: 2, PageCount: 3, ItemCount: 5}, want: 2},
+		{name: "last page", meta: &Pagination{Page: 3, PageSize: 2, PageCount: 3, ItemCount: 5}, want: 0},
+		{name: "empty", meta: &Pagination{Page: 1, PageSize: 2}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewPage([]int{1}, tt.meta).NextPage; got != tt.want {
+				t.Fatalf("got next page %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageOf(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+
+	tests := []struct {
+		name     string
+		opts     *PageOptions
+		want     []int
+		wantNext int
+	}{
+		{name: "first page", opts: &PageOptions{Page: 1, PageSize: 2}, want: []int{1, 2}, wantNext: 2},
+		{name: "middle page", opts: &PageOptions{Page: 2, PageSize: 2}, want: []int{3, 4}, wantNext: 3},
+		{name: "last page", opts: &PageOptions{Page: 3, PageSize: 2}, want: []int{5}},
+		{name: "exact last page", opts: &PageOptions{Page: 1, PageSize: 5}, want: []int{1, 2, 3, 4, 5}},
+		{name: "past the last page", opts: &PageOptions{Page: 4, PageSize: 2}},
+		{name: "no page size", opts: &PageOptions{Page: 1}, want: []int{1, 2, 3, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := PageOf(items, tt.opts)
+
+			if len(page.Items) != len(tt.want) || page.NextPage != tt.wantNext {
+				t.Fatalf("got %v and next page %d, want %v and %d", page.Items, page.NextPage, tt.want, tt.wantNext)
+			}
+			for i := range tt.want {
+				if page.Items[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", page.Items, tt.want)
+				}
+			}
+		})
+	}
+}
+
+type testContact struct {
+	Id int `json:"ContactID"`
+}
+
+type testContactsResponse struct {
+	Contacts   []testContact `json:"Contacts"`
+	Pagination *Pagination   `json:"pagination"`
+}
+
+func TestFetchPages(t *testing.T) {
+	const itemCount = 5
+
+	var pages []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+		pages = append(pages, r.URL.RawQuery)
+
+		res := testContactsResponse{Pagination: &Pagination{
+			Page:      page,
+			PageSize:  pageSize,
+			PageCount: (itemCount + pageSize - 1) / pageSize,
+			ItemCount: itemCount,
+		}}
+		for id := (page-1)*pageSize + 1; id <= page*pageSize && id <= itemCount; id++ {
+			res.Contacts = append(res.Contacts, testContact{Id: id})
+		}
+
+		_ = json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(srv.Close)
+
+	api, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoints := DefaultEndpoints()
+	endpoints.API = api
+
+	ctx := context.Background()
+	client, err := NewClient(ctx, srv.Client(), NewAuth("token", "", "", "", nil), WithEndpoints(endpoints))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contacts, _, err := FetchPages(ctx, 2, func(ctx context.Context, opts *PageOptions) (*Page[testContact], *v2.RateLimitDescription, error) {
+		var res testContactsResponse
+
+		rateLimit, err := client.get(ctx, client.joinURL("/Contacts"), "tenant", &res, nil, opts)
+		if err != nil {
+			return nil, rateLimit, err
+		}
+
+		return NewPage(res.Contacts, res.Pagination), rateLimit, nil
+	})
+	if err != nil {
+		t.Fatalf("failed to fetch pages: %v", err)
+	}
+
+	if len(contacts) != itemCount {
+		t.Fatalf("got contacts %v, want %d", contacts, itemCount)
+	}
+	for i, contact := range contacts {
+		if contact.Id != i+1 {
+			t.Fatalf("got contacts %v, want them in order", contacts)
+		}
+	}
+
+	want := []string{"page=1&pageSize=2", "page=2&pageSize=2", "page=3&pageSize=2"}
+	if len(pages) != len(want) {
+		t.Fatalf("requested pages %v, want %v", pages, want)
+	}
+	for i := range want {
+		if pages[i] != want[i] {
+			t.Fatalf("requested pages %v, want %v", pages, want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package xero
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	fieldPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*(\.[A-Za-z][A-Za-z0-9_]*)*$`)
+	guidPattern  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// Value is a literal compared to a field in a where clause.
+type Value struct {
+	literal string
+	err     error
+}
+
+// String returns a string literal, quotes and backslashes are escaped.
+func String(s string) Value {
+	return Value{literal: quote(s)}
+}
+
+// Bool returns a boolean literal.
+func Bool(b bool) Value {
+	return Value{literal: strconv.FormatBool(b)}
+}
+
+// Int returns an integer literal.
+func Int(n int64) Value {
+	return Value{literal: strconv.FormatInt(n, 10)}
+}
+
+// Guid returns a Guid("...") literal, used to compare ID fields.
+func Guid(id string) Value {
+	if !guidPattern.MatchString(id) {
+		return Value{err: fmt.Errorf("invalid guid %q", id)}
+	}
+
+	return Value{literal: fmt.Sprintf("Guid(%s)", quote(id))}
+}
+
+// DateTime returns a DateTime(...) literal of the UTC time, to the second.
+func DateTime(t time.Time) Value {
+	t = t.UTC()
+
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return Value{literal: fmt.Sprintf("DateTime(%d, %02d, %02d)", t.Year(), t.Month(), t.Day())}
+	}
+
+	return Value{literal: fmt.Sprintf(
+		"DateTime(%d, %02d, %02d, %02d, %02d, %02d)",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(),
+	)}
+}
+
+func quote(s string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+	return `"` + escaped + `"`
+}
+
+// Filter is a condition of a where clause, the zero value matches every record.
+type Filter struct {
+	expr string
+	err  error
+}
+
+func comparison(field, operator string, value Value) Filter {
+	if !fieldPattern.MatchString(field) {
+		return Filter{err: fmt.Errorf("invalid field name %q", field)}
+	}
+
+	if value.err != nil {
+		return Filter{err: fmt.Errorf("invalid value for %s: %w", field, value.err)}
+	}
+
+	return Filter{expr: field + operator + value.literal}
+}
+
+// Eq matches records whose field equals the value.
+func Eq(field string, value Value) Filter {
+	return comparison(field, "==", value)
+}
+
+// NotEq matches records whose field differs from the value.
+func NotEq(field string, value Value) Filter {
+	return comparison(field, "!=", value)
+}
+
+// Gt matches records whose field is greater than the value.
+func Gt(field string, value Value) Filter {
+	return comparison(field, ">", value)
+}
+
+// Gte matches records whose field is greater than or equal to the value.
+func Gte(field string, value Value) Filter {
+	return comparison(field, ">=", value)
+}
+
+// Lt matches records whose field is less than the value.
+func Lt(field string, value Value) Filter {
+	return comparison(field, "<", value)
+}
+
+// Lte matches records whose field is less than or equal to the value.
+func Lte(field string, value Value) Filter {
+	return comparison(field, "<=", value)
+}
+
+// Contains matches records whose string field contains s.
+func Contains(field, s string) Filter {
+	return comparison(field, ".Contains(", Value{literal: quote(s) + ")"})
+}
+
+// StartsWith matches records whose string field starts with s.
+func StartsWith(field, s string) Filter {
+	return comparison(field, ".StartsWith(", Value{literal: quote(s) + ")"})
+}
+
+// EndsWith matches records whose string field ends with s.
+func EndsWith(field, s string) Filter {
+	return comparison(field, ".EndsWith(", Value{literal: quote(s) + ")"})
+}
+
+// And matches records matching every filter.
+func And(filters ...Filter) Filter {
+	return join(" AND ", filters)
+}
+
+// Or matches records matching any of the filters.
+func Or(filters ...Filter) Filter {
+	return join(" OR ", filters)
+}
+
+func join(operator string, filters []Filter) Filter {
+	exprs := make([]string, 0, len(filters))
+	for _, f := range filters {
+		if f.err != nil {
+			return f
+		}
+		if f.expr != "" {
+			exprs = append(exprs, f.expr)
+		}
+	}
+
+	switch len(exprs) {
+	case 0:
+		return Filter{}
+	case 1:
+		return Filter{expr: exprs[0]}
+	}
+
+	return Filter{expr: "(" + strings.Join(exprs, operator) + ")"}
+}
+
+// String returns the where clause of the filter.
+func (f Filter) String() string {
+	return f.expr
+}
+
+// Order sorts the records by a field.
+type Order struct {
+	Field      string
+	Descending bool
+}
+
+// Asc sorts the records by the field in ascending order.
+func Asc(field string) Order {
+	return Order{Field: field}
+}
+
+// Desc sorts the records by the field in descending order.
+func Desc(field string) Order {
+	return Order{Field: field, Descending: true}
+}
+
+// Query filters and sorts the records returned by an endpoint.
+type Query struct {
+	Where Filter
+	Order []Order
+}
+
+func (q *Query) apply(u *url.URL) error {
+	if q == nil {
+		return nil
+	}
+
+	if q.Where.err != nil {
+		return fmt.Errorf("invalid where clause: %w", q.Where.err)
+	}
+
+	values := u.Query()
+
+	if q.Where.expr != "" {
+		values.Set("where", q.Where.expr)
+	}
+
+	if len(q.Order) > 0 {
+		orders := make([]string, 0, len(q.Order))
+		for _, o := range q.Order {
+			if !fieldPattern.MatchString(o.Field) {
+				return fmt.Errorf("invalid order field %q", o.Field)
+			}
+
+			if o.Descending {
+				orders = append(orders, o.Field+" DESC")
+			} else {
+				orders = append(orders, o.Field)
+			}
+		}
+		values.Set("order", strings.Join(orders, ","))
+	}
+
+	u.RawQuery = values.Encode()
+
+	return nil
+}
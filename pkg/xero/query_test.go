@@ -0,0 +1,109 @@
+package xero
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestFilterString(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{name: "zero", filter: Filter{}, want: ""},
+		{name: "string", filter: Eq("Name", String("Demo")), want: `Name=="Demo"`},
+		{name: "quote", filter: Eq("Name", String(`Bob "Builder"`)), want: `Name=="Bob \"Builder\""`},
+		{name: "backslash", filter: Eq("Name", String(`a\b`)), want: `Name=="a\\b"`},
+		{
+			name:   "quote injection",
+			filter: Eq("Name", String(`x" OR IsSubscriber==true OR Name=="`)),
+			want:   `Name=="x\" OR IsSubscriber==true OR Name==\""`,
+		},
+		{
+			name:   "escaped quote injection",
+			filter: Eq("Name", String(`x\" OR true`)),
+			want:   `Name=="x\\\" OR true"`,
+		},
+		{name: "bool", filter: NotEq("IsSubscriber", Bool(true)), want: `IsSubscriber!=true`},
+		{name: "int", filter: Gte("Count", Int(-3)), want: `Count>=-3`},
+		{
+			name:   "guid",
+			filter: Eq("UserID", Guid("a0000000-0000-4000-8000-000000000001")),
+			want:   `UserID==Guid("a0000000-0000-4000-8000-000000000001")`,
+		},
+		{
+			name:   "date",
+			filter: Lt("UpdatedDateUTC", DateTime(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))),
+			want:   `UpdatedDateUTC<DateTime(2024, 03, 01)`,
+		},
+		{
+			name:   "date time in utc",
+			filter: Gt("UpdatedDateUTC", DateTime(time.Date(2024, 3, 1, 10, 5, 9, 0, time.FixedZone("NZDT", 13*3600)))),
+			want:   `UpdatedDateUTC>DateTime(2024, 02, 29, 21, 05, 09)`,
+		},
+		{name: "contains", filter: Contains("EmailAddress", `"@x`), want: `EmailAddress.Contains("\"@x")`},
+		{name: "starts with", filter: StartsWith("FirstName", "Al"), want: `FirstName.StartsWith("Al")`},
+		{name: "ends with", filter: EndsWith("Contact.Name", "Ltd"), want: `Contact.Name.EndsWith("Ltd")`},
+		{
+			name:   "and or",
+			filter: And(Eq("A", Int(1)), Or(Eq("B", Int(2)), Filter{}, Eq("C", Int(3)))),
+			want:   `(A==1 AND (B==2 OR C==3))`,
+		},
+		{name: "single and", filter: And(Filter{}, Eq("A", Int(1))), want: `A==1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.filter.err != nil {
+				t.Fatalf("unexpected error: %v", tt.filter.err)
+			}
+
+			if got := tt.filter.String(); got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   *Query
+		want    url.Values
+		wantErr bool
+	}{
+		{name: "nil", query: nil, want: url.Values{"page": {"2"}}},
+		{
+			name:  "where and order",
+			query: &Query{Where: Eq("Name", String("a&b")), Order: []Order{Asc("Name"), Desc("UpdatedDateUTC")}},
+			want:  url.Values{"page": {"2"}, "where": {`Name=="a&b"`}, "order": {"Name,UpdatedDateUTC DESC"}},
+		},
+		{name: "invalid field", query: &Query{Where: Eq("Name==1 OR X", Int(1))}, wantErr: true},
+		{name: "invalid guid", query: &Query{Where: Eq("UserID", Guid(`") OR true`))}, wantErr: true},
+		{name: "invalid nested filter", query: &Query{Where: And(Eq("A", Int(1)), Eq("1B", Int(2)))}, wantErr: true},
+		{name: "invalid order field", query: &Query{Order: []Order{Desc("Name;")}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := url.Parse("https://api.xero.com/api.xro/2.0/Users?page=2")
+
+			err := tt.query.apply(u)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("applying the query succeeded with %s, want an error", u.RawQuery)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to apply the query: %v", err)
+			}
+
+			if got := u.Query().Encode(); got != tt.want.Encode() {
+				t.Fatalf("got query %s, want %s", got, tt.want.Encode())
+			}
+		})
+	}
+}
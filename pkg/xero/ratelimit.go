@@ -0,0 +1,147 @@
+package xero
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Xero rate limit headers, see https://developer.xero.com/documentation/guides/oauth2/limits.
+const (
+	headerMinLimitRemaining    = "X-MinLimit-Remaining"
+	headerDayLimitRemaining    = "X-DayLimit-Remaining"
+	headerAppMinLimitRemaining = "X-AppMinLimit-Remaining"
+	headerRateLimitProblem     = "X-Rate-Limit-Problem"
+	headerRetryAfter           = "Retry-After"
+)
+
+// Limits enforced by Xero.
+const (
+	ConcurrentLimit = 5
+	MinuteLimit     = 60
+	DayLimit        = 5000
+	AppMinuteLimit  = 10000
+)
+
+const (
+	// maxRateLimitRetries is how many times a request rejected with 429 is retried.
+	maxRateLimitRetries = 3
+	// maxRateLimitWait is the longest Retry-After the client sleeps for. Longer waits, like
+	// after exhausting the daily limit, fail the request instead.
+	maxRateLimitWait = 65 * time.Second
+	// defaultRetryAfter is used when a 429 response has no usable Retry-After header.
+	defaultRetryAfter = 5 * time.Second
+)
+
+// RateLimit is the rate limit state reported by Xero in the headers of a response.
+// Remaining values are -1 when the header is missing.
+type RateLimit struct {
+	MinRemaining    int
+	DayRemaining    int
+	AppMinRemaining int
+	// Problem names the exceeded limit on a 429 response: minute, day or appminute.
+	Problem    string
+	RetryAfter time.Duration
+	Limited    bool
+}
+
+func parseRateLimit(resp *http.Response) *RateLimit {
+	rl := &RateLimit{
+		MinRemaining:    headerInt(resp.Header, headerMinLimitRemaining),
+		DayRemaining:    headerInt(resp.Header, headerDayLimitRemaining),
+		AppMinRemaining: headerInt(resp.Header, headerAppMinLimitRemaining),
+		Problem:         resp.Header.Get(headerRateLimitProblem),
+		Limited:         resp.StatusCode == http.StatusTooManyRequests,
+	}
+
+	if rl.Limited {
+		rl.RetryAfter = retryAfter(resp.Header)
+	}
+
+	return rl
+}
+
+func headerInt(h http.Header, key string) int {
+	v, err := strconv.Atoi(h.Get(key))
+	if err != nil {
+		return -1
+	}
+
+	return v
+}
+
+// retryAfter parses the Retry-After header, given either in seconds or as an HTTP date.
+func retryAfter(h http.Header) time.Duration {
+	v := h.Get(headerRetryAfter)
+
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(v); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+
+	return defaultRetryAfter
+}
+
+// Description converts the state into the annotation the baton sync loop paces itself with.
+// It describes the exceeded limit, or else the limit closest to exhaustion.
+func (rl *RateLimit) Description() *v2.RateLimitDescription {
+	if rl == nil {
+		return nil
+	}
+
+	now := time.Now()
+	nextMinute := now.Truncate(time.Minute).Add(time.Minute)
+	nextDay := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+
+	limits := []struct {
+		problem   string
+		limit     int
+		remaining int
+		resetAt   time.Time
+	}{
+		{"minute", MinuteLimit, rl.MinRemaining, nextMinute},
+		{"appminute", AppMinuteLimit, rl.AppMinRemaining, nextMinute},
+		{"day", DayLimit, rl.DayRemaining, nextDay},
+	}
+
+	desc := &v2.RateLimitDescription{
+		Status:  v2.RateLimitDescription_STATUS_OK,
+		Limit:   MinuteLimit,
+		ResetAt: timestamppb.New(nextMinute),
+	}
+
+	lowest := 2.0
+	for _, l := range limits {
+		if l.remaining < 0 {
+			continue
+		}
+
+		ratio := float64(l.remaining) / float64(l.limit)
+		if l.problem == rl.Problem {
+			ratio = -1
+		}
+
+		if ratio < lowest {
+			lowest = ratio
+			desc.Limit = int64(l.limit)
+			desc.Remaining = int64(l.remaining)
+			desc.ResetAt = timestamppb.New(l.resetAt)
+		}
+	}
+
+	if rl.Limited {
+		desc.Status = v2.RateLimitDescription_STATUS_OVERLIMIT
+		desc.Remaining = 0
+		desc.ResetAt = timestamppb.New(now.Add(rl.RetryAfter))
+	}
+
+	return desc
+}
@@ -0,0 +1,69 @@
+package xero
+
+import (
+	"sort"
+	"strings"
+)
+
+// Feature is a part of the Xero API a resource syncer depends on.
+type Feature string
+
+const (
+	FeatureOrganisations Feature = "organisations"
+	FeatureUsers         Feature = "users"
+	FeatureConnections   Feature = "connections"
+)
+
+// IdentityScopes are requested by the flows acting on behalf of a user. offline_access is
+// required to be issued a refresh token.
+var IdentityScopes = []string{"openid", "email", "profile", "offline_access"}
+
+// scopesByFeature are the scopes of each feature. Xero's granular scopes only split the broad
+// accounting.transactions and accounting.reports.read scopes; accounting.settings.read, which
+// covers organisations and users, is unchanged, so apps with granular scopes request the same
+// ones and no separate granular mapping is kept.
+var scopesByFeature = map[Feature][]string{
+	FeatureOrganisations: {"accounting.settings.read"},
+	FeatureUsers:         {"accounting.settings.read"},
+	// /connections only requires a valid access token
+	FeatureConnections: nil,
+}
+
+// PlanScopes returns the API scopes required by the given features, sorted and without duplicates.
+func PlanScopes(features ...Feature) []string {
+	seen := make(map[string]bool)
+
+	var rv []string
+	for _, feature := range features {
+		for _, scope := range scopesByFeature[feature] {
+			if !seen[scope] {
+				seen[scope] = true
+				rv = append(rv, scope)
+			}
+		}
+	}
+
+	sort.Strings(rv)
+
+	return rv
+}
+
+// MissingScopes returns the required scopes not covered by the granted ones.
+// A granted scope also covers its read-only variant, e.g. accounting.settings covers accounting.settings.read.
+func MissingScopes(granted, required []string) []string {
+	grantedSet := make(map[string]bool, len(granted))
+	for _, scope := range granted {
+		grantedSet[scope] = true
+	}
+
+	var rv []string
+	for _, scope := range required {
+		if grantedSet[scope] || grantedSet[strings.TrimSuffix(scope, ".read")] {
+			continue
+		}
+
+		rv = append(rv, scope)
+	}
+
+	return rv
+}
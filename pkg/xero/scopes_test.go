@@ -0,0 +1,49 @@
+package xero
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		features []Feature
+		want     []string
+	}{
+		{name: "none"},
+		{name: "connections only", features: []Feature{FeatureConnections}},
+		{name: "deduplicated", features: []Feature{FeatureUsers, FeatureOrganisations, FeatureConnections}, want: []string{"accounting.settings.read"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PlanScopes(tt.features...); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingScopes(t *testing.T) {
+	required := []string{"accounting.settings.read"}
+
+	tests := []struct {
+		name    string
+		granted []string
+		want    []string
+	}{
+		{name: "none granted", want: required},
+		{name: "read granted", granted: []string{"openid", "accounting.settings.read"}},
+		{name: "write covers read", granted: []string{"accounting.settings"}},
+		{name: "other scopes", granted: []string{"accounting.transactions.read", "accounting.settings.write"}, want: required},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MissingScopes(tt.granted, required); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
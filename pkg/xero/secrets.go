@@ -0,0 +1,180 @@
+package xero
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// credentialProcessCacheTTL is how long the output of a credential process is reused, so
+// that resolving several secrets for a single login runs the process only once.
+const credentialProcessCacheTTL = 10 * time.Second
+
+// SecretSource resolves a credential. Sources are read again on every login, so that
+// secrets rotated outside of the connector are picked up without a restart.
+type SecretSource interface {
+	Secret(ctx context.Context) (string, error)
+}
+
+// SecretSources are the optional sources of the app credentials, overriding the literal values of Auth.
+type SecretSources struct {
+	ClientId     SecretSource
+	ClientSecret SecretSource
+	AccessToken  SecretSource
+	RefreshToken SecretSource
+}
+
+// SecretWriter is implemented by the sources a rotated secret can be written back to, so
+// that the next read returns it.
+type SecretWriter interface {
+	WriteSecret(ctx context.Context, secret string) error
+}
+
+// FileSecret reads the secret from a file, ignoring surrounding whitespace.
+type FileSecret struct {
+	Path string
+}
+
+func (s *FileSecret) Secret(_ context.Context) (string, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// WriteSecret replaces the content of the file with the secret.
+func (s *FileSecret) WriteSecret(_ context.Context, secret string) error {
+	if err := writeFileAtomic(s.Path, []byte(secret+"\n")); err != nil {
+		return fmt.Errorf("failed to write secret file: %w", err)
+	}
+
+	return nil
+}
+
+// StdinSecret reads the secret from the standard input. The input can only be consumed
+// once, so the secret is read on first use and reused afterwards.
+type StdinSecret struct {
+	once   sync.Once
+	secret string
+	err    error
+}
+
+func (s *StdinSecret) Secret(_ context.Context) (string, error) {
+	s.once.Do(func() {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			s.err = fmt.Errorf("failed to read secret from stdin: %w", err)
+			return
+		}
+
+		s.secret = strings.TrimSpace(string(data))
+	})
+
+	return s.secret, s.err
+}
+
+// CredentialProcessOutput is the JSON document a credential process writes to its standard output.
+type CredentialProcessOutput struct {
+	Version      int    `json:"Version"`
+	ClientId     string `json:"ClientId"`
+	ClientSecret string `json:"ClientSecret"`
+	AccessToken  string `json:"AccessToken"`
+	RefreshToken string `json:"RefreshToken"`
+}
+
+// CredentialProcess runs an external command printing the credentials as JSON, like
+// the credential_process setting of the AWS CLI.
+type CredentialProcess struct {
+	Command string
+
+	mu        sync.Mutex
+	output    *CredentialProcessOutput
+	fetchedAt time.Time
+}
+
+func NewCredentialProcess(command string) *CredentialProcess {
+	return &CredentialProcess{Command: command}
+}
+
+// Output runs the command, or returns its output if it ran recently.
+func (p *CredentialProcess) Output(ctx context.Context) (*CredentialProcessOutput, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.output != nil && time.Since(p.fetchedAt) < credentialProcessCacheTTL {
+		return p.output, nil
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", p.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", p.Command)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("credential process failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var output CredentialProcessOutput
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return nil, fmt.Errorf("credential process returned invalid JSON: %w", err)
+	}
+
+	if output.Version != 1 {
+		return nil, fmt.Errorf("credential process returned unsupported version %d, expected 1", output.Version)
+	}
+
+	p.output = &output
+	p.fetchedAt = time.Now()
+
+	return p.output, nil
+}
+
+// ClientId returns a source reading the client id from the process output.
+func (p *CredentialProcess) ClientId() SecretSource {
+	return &credentialProcessField{process: p, field: func(o *CredentialProcessOutput) string { return o.ClientId }}
+}
+
+// ClientSecret returns a source reading the client secret from the process output.
+func (p *CredentialProcess) ClientSecret() SecretSource {
+	return &credentialProcessField{process: p, field: func(o *CredentialProcessOutput) string { return o.ClientSecret }}
+}
+
+// AccessToken returns a source reading the access token from the process output.
+func (p *CredentialProcess) AccessToken() SecretSource {
+	return &credentialProcessField{process: p, field: func(o *CredentialProcessOutput) string { return o.AccessToken }}
+}
+
+// RefreshToken returns a source reading the refresh token from the process output.
+func (p *CredentialProcess) RefreshToken() SecretSource {
+	return &credentialProcessField{process: p, field: func(o *CredentialProcessOutput) string { return o.RefreshToken }}
+}
+
+type credentialProcessField struct {
+	process *CredentialProcess
+	field   func(*CredentialProcessOutput) string
+}
+
+func (f *credentialProcessField) Secret(ctx context.Context) (string, error) {
+	output, err := f.process.Output(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return f.field(output), nil
+}
@@ -0,0 +1,230 @@
+package xero
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		content *string
+		want    string
+		wantErr bool
+	}{
+		{name: "secret", content: stringPtr("secret"), want: "secret"},
+		{name: "surrounding whitespace", content: stringPtr("  secret \n"), want: "secret"},
+		{name: "empty", content: stringPtr(""), want: ""},
+		{name: "missing file", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "secret")
+			if tt.content != nil {
+				if err := os.WriteFile(path, []byte(*tt.content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := (&FileSecret{Path: path}).Secret(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Fatalf("got secret %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileSecretWriteSecret(t *testing.T) {
+	ctx := context.Background()
+	source := &FileSecret{Path: filepath.Join(t.TempDir(), "secret")}
+
+	for _, secret := range []string{"first", "second"} {
+		if err := source.WriteSecret(ctx, secret); err != nil {
+			t.Fatalf("failed to write secret: %v", err)
+		}
+
+		if got, err := source.Secret(ctx); err != nil || got != secret {
+			t.Fatalf("read back %q (%v), want %q", got, err, secret)
+		}
+	}
+
+	info, err := os.Stat(source.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("got permissions %o, want 600", perm)
+	}
+}
+
+func TestStdinSecret(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "secret", input: "secret", want: "secret"},
+		{name: "trailing newline", input: "secret\n", want: "secret"},
+		{name: "empty", input: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stdin := os.Stdin
+			os.Stdin = r
+			t.Cleanup(func() {
+				os.Stdin = stdin
+				r.Close()
+			})
+
+			if _, err := w.WriteString(tt.input); err != nil {
+				t.Fatal(err)
+			}
+			w.Close()
+
+			source := &StdinSecret{}
+
+			// the input is consumed once and the secret reused afterwards
+			for i := 0; i < 2; i++ {
+				got, err := source.Secret(context.Background())
+				if err != nil {
+					t.Fatalf("failed to read secret: %v", err)
+				}
+
+				if got != tt.want {
+					t.Fatalf("got secret %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCredentialProcess(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    CredentialProcessOutput
+		wantErr string
+	}{
+		{
+			name:   "version 1",
+			output: `{"Version": 1, "ClientId": "id", "ClientSecret": "secret", "RefreshToken": "refresh"}`,
+			want:   CredentialProcessOutput{Version: 1, ClientId: "id", ClientSecret: "secret", RefreshToken: "refresh"},
+		},
+		{
+			name:    "unsupported version",
+			output:  `{"Version": 2, "ClientId": "id"}`,
+			wantErr: "unsupported version 2",
+		},
+		{
+			name:    "missing version",
+			output:  `{"ClientId": "id"}`,
+			wantErr: "unsupported version 0",
+		},
+		{
+			name:    "invalid JSON",
+			output:  `ClientId=id`,
+			wantErr: "invalid JSON",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			process := NewCredentialProcess(credentialCommand(t, tt.output))
+
+			got, err := process.Output(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("credential process failed: %v", err)
+			}
+
+			if *got != tt.want {
+				t.Fatalf("got output %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialProcessFails(t *testing.T) {
+	process := NewCredentialProcess("echo 'not logged in' >&2; exit 3")
+
+	_, err := process.Output(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "not logged in") {
+		t.Fatalf("got error %v, want the stderr of the process", err)
+	}
+}
+
+func TestCredentialProcessCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	runs := filepath.Join(t.TempDir(), "runs")
+
+	process := NewCredentialProcess("echo run >> " + runs + "; echo '{\"Version\": 1, \"ClientId\": \"id\"}'")
+
+	countRuns := func() int {
+		data, err := os.ReadFile(runs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return strings.Count(string(data), "run")
+	}
+
+	// the fields of a single login share one run
+	for _, source := range []SecretSource{process.ClientId(), process.ClientSecret(), process.RefreshToken()} {
+		if _, err := source.Secret(ctx); err != nil {
+			t.Fatalf("failed to read secret: %v", err)
+		}
+	}
+
+	if got := countRuns(); got != 1 {
+		t.Fatalf("process ran %d times, want once", got)
+	}
+
+	// the output is stale once the TTL has passed
+	process.fetchedAt = process.fetchedAt.Add(-credentialProcessCacheTTL - time.Second)
+
+	if got, err := process.ClientId().Secret(ctx); err != nil || got != "id" {
+		t.Fatalf("got client id %q (%v), want id", got, err)
+	}
+
+	if got := countRuns(); got != 2 {
+		t.Fatalf("process ran %d times, want twice", got)
+	}
+}
+
+// credentialCommand returns a command printing the output.
+func credentialCommand(t *testing.T, output string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, []byte(output), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return "cat " + path
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,285 @@
+package xero
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// fullFetchInterval is how often a collection is fetched in full rather than only the records
+// modified since the previous sync. Xero does not report deleted records, so only full fetches
+// drop them.
+const fullFetchInterval = 24 * time.Hour
+
+// CollectionState is what the previous syncs fetched of a collection of a tenant: the records
+// themselves, when they were last fetched and when they were last fetched in full.
+type CollectionState struct {
+	FetchedAt     time.Time         `json:"fetchedAt"`
+	FullFetchedAt time.Time         `json:"fullFetchedAt"`
+	Records       []json.RawMessage `json:"records"`
+}
+
+// SyncState persists the collections fetched by syncs in a local file, so that later syncs
+// only fetch the records modified since. The file holds user details and is only readable by
+// the current user.
+type SyncState struct {
+	path string
+
+	mu          sync.Mutex
+	loaded      bool
+	collections map[string]*CollectionState
+}
+
+// NewSyncState returns the sync state persisted at path.
+func NewSyncState(path string) *SyncState {
+	return &SyncState{path: path}
+}
+
+func (s *SyncState) load() error {
+	if s.loaded {
+		return nil
+	}
+
+	s.collections = make(map[string]*CollectionState)
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			s.loaded = true
+			return nil
+		}
+		return fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.collections); err != nil {
+		return fmt.Errorf("failed to parse sync state %s: %w", s.path, err)
+	}
+
+	s.loaded = true
+
+	return nil
+}
+
+func (s *SyncState) collection(key string) (CollectionState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return CollectionState{}, err
+	}
+
+	cs, ok := s.collections[key]
+	if !ok {
+		return CollectionState{}, nil
+	}
+
+	return *cs, nil
+}
+
+func (s *SyncState) save(key string, cs *CollectionState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	s.collections[key] = cs
+
+	data, err := json.Marshal(s.collections)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+
+	return nil
+}
+
+type modifiedSinceKey struct{}
+
+func withModifiedSince(ctx context.Context, since time.Time) context.Context {
+	return context.WithValue(ctx, modifiedSinceKey{}, since)
+}
+
+func setModifiedSince(ctx context.Context, req *http.Request) {
+	since, ok := ctx.Value(modifiedSinceKey{}).(time.Time)
+	if ok && !since.IsZero() {
+		req.Header.Set("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+	}
+}
+
+// fetchIncremental returns every record of a collection of the tenant. With a sync state,
+// only the records modified since the previous sync are fetched and merged into the stored
+// ones, unless the collection was last fetched in full more than fullFetchInterval ago. A full
+// fetch logs the records removed since the previous one, which the deltas cannot report.
+func fetchIncremental[T any](
+	ctx context.Context,
+	state *SyncState,
+	tenantId string,
+	collection string,
+	id func(T) string,
+	fetch func(ctx context.Context) ([]T, *v2.RateLimitDescription, error),
+) ([]T, *v2.RateLimitDescription, error) {
+	if state == nil {
+		return fetch(ctx)
+	}
+
+	key := tenantId + "/" + collection
+
+	cs, err := state.collection(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored, err := decodeRecords[T](cs.Records)
+	if err != nil {
+		ctxzap.Extract(ctx).Warn(
+			"xero-connector: ignoring unreadable records of the sync state",
+			zap.String("tenant_id", tenantId),
+			zap.String("collection", collection),
+			zap.Error(err),
+		)
+		cs = CollectionState{}
+	}
+
+	// the records modified while the request is served are fetched again by the next sync
+	fetchedAt := time.Now().UTC()
+	full := cs.FullFetchedAt.IsZero() || fetchedAt.Sub(cs.FullFetchedAt) >= fullFetchInterval
+
+	var (
+		records   []T
+		rateLimit *v2.RateLimitDescription
+	)
+	if full {
+		records, rateLimit, err = fetch(ctx)
+		if err != nil {
+			return nil, rateLimit, err
+		}
+
+		logRemoved(ctx, tenantId, collection, cs.FullFetchedAt, recordIds(stored, id), recordIds(records, id))
+		cs.FullFetchedAt = fetchedAt
+	} else {
+		var modified []T
+		modified, rateLimit, err = fetch(withModifiedSince(ctx, cs.FetchedAt))
+		if err != nil {
+			return nil, rateLimit, err
+		}
+
+		records = mergeRecords(stored, modified, id)
+	}
+
+	cs.FetchedAt = fetchedAt
+	cs.Records, err = encodeRecords(records)
+	if err != nil {
+		return nil, rateLimit, err
+	}
+
+	if err := state.save(key, &cs); err != nil {
+		return nil, rateLimit, err
+	}
+
+	return records, rateLimit, nil
+}
+
+// mergeRecords replaces the stored records with their modified versions, keeping their
+// order, and appends the records added since.
+func mergeRecords[T any](stored, modified []T, id func(T) string) []T {
+	index := make(map[string]int, len(stored))
+	merged := make([]T, 0, len(stored)+len(modified))
+	for _, record := range stored {
+		index[id(record)] = len(merged)
+		merged = append(merged, record)
+	}
+
+	for _, record := range modified {
+		if i, ok := index[id(record)]; ok {
+			merged[i] = record
+			continue
+		}
+
+		index[id(record)] = len(merged)
+		merged = append(merged, record)
+	}
+
+	return merged
+}
+
+func decodeRecords[T any](raw []json.RawMessage) ([]T, error) {
+	records := make([]T, 0, len(raw))
+	for _, data := range raw {
+		var record T
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func encodeRecords[T any](records []T) ([]json.RawMessage, error) {
+	raw := make([]json.RawMessage, 0, len(records))
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, data)
+	}
+
+	return raw, nil
+}
+
+// recordIds returns the sorted IDs of the records.
+func recordIds[T any](records []T, id func(T) string) []string {
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, id(record))
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// logRemoved logs the IDs of previous missing from current, both sorted.
+func logRemoved(ctx context.Context, tenantId, collection string, previousFetch time.Time, previous, current []string) {
+	removed := removedIds(previous, current)
+	if len(removed) == 0 {
+		return
+	}
+
+	ctxzap.Extract(ctx).Info(
+		"xero-connector: records removed since the previous full fetch",
+		zap.String("tenant_id", tenantId),
+		zap.String("collection", collection),
+		zap.Time("previous_full_fetch", previousFetch),
+		zap.Strings("ids", removed),
+	)
+}
+
+// removedIds returns the IDs of previous missing from current, both sorted.
+func removedIds(previous, current []string) []string {
+	var removed []string
+	for _, id := range previous {
+		i := sort.SearchStrings(current, id)
+		if i == len(current) || current[i] != id {
+			removed = append(removed, id)
+		}
+	}
+
+	return removed
+}
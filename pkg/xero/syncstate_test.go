@@ -0,0 +1,201 @@
+package xero
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+)
+
+func TestRemovedIds(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous []string
+		current  []string
+		want     []string
+	}{
+		{name: "first sync", current: []string{"a", "b"}},
+		{name: "unchanged", previous: []string{"a", "b"}, current: []string{"a", "b"}},
+		{name: "added", previous: []string{"b"}, current: []string{"a", "b", "c"}},
+		{name: "removed", previous: []string{"a", "b", "c", "d"}, current: []string{"b", "d"}, want: []string{"a", "c"}},
+		{name: "all removed", previous: []string{"a", "b"}, want: []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := removedIds(tt.previous, tt.current); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeRecords(t *testing.T) {
+	tests := []struct {
+		name     string
+		stored   []User
+		modified []User
+		want     []User
+	}{
+		{name: "first sync", modified: []User{{Id: "a"}}, want: []User{{Id: "a"}}},
+		{name: "nothing modified", stored: []User{{Id: "a"}, {Id: "b"}}, want: []User{{Id: "a"}, {Id: "b"}}},
+		{
+			name:     "modified",
+			stored:   []User{{Id: "a", Role: "STANDARD"}, {Id: "b", Role: "STANDARD"}},
+			modified: []User{{Id: "b", Role: "ADMIN"}},
+			want:     []User{{Id: "a", Role: "STANDARD"}, {Id: "b", Role: "ADMIN"}},
+		},
+		{
+			name:     "added",
+			stored:   []User{{Id: "b"}},
+			modified: []User{{Id: "c"}, {Id: "a"}},
+			want:     []User{{Id: "b"}, {Id: "c"}, {Id: "a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeRecords(tt.stored, tt.modified, func(u User) string { return u.Id })
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchIncremental(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+	key := "tenant" + "/" + UsersEndpoint
+
+	// a state written by an earlier version, holding the user IDs only
+	legacy := `{"tenant//Users":{"syncedAt":"2024-03-01T00:00:00Z","ids":["b"]}}`
+	if err := os.WriteFile(path, []byte(legacy), 0o600); err != nil {
+		t.Fatalf("failed to write state: %v", err)
+	}
+
+	fetches := []struct {
+		name string
+		// ageFullFetch moves the previous full fetch back by the interval
+		ageFullFetch bool
+		users        []User
+		wantFull     bool
+		want         []string
+	}{
+		{
+			name:     "legacy state",
+			users:    []User{{Id: "a", Email: "alice@example.com"}, {Id: "b", Email: "bob@example.com"}, {Id: "c", Email: "carol@example.com"}},
+			wantFull: true,
+			want:     []string{"a:alice@example.com", "b:bob@example.com", "c:carol@example.com"},
+		},
+		{
+			name:  "modified since",
+			users: []User{{Id: "b", Email: "robert@example.com"}, {Id: "d", Email: "dave@example.com"}},
+			want:  []string{"a:alice@example.com", "b:robert@example.com", "c:carol@example.com", "d:dave@example.com"},
+		},
+		{
+			name: "nothing modified",
+			want: []string{"a:alice@example.com", "b:robert@example.com", "c:carol@example.com", "d:dave@example.com"},
+		},
+		{
+			name:         "full fetch drops the deleted users",
+			ageFullFetch: true,
+			users:        []User{{Id: "a", Email: "alice@example.com"}, {Id: "d", Email: "dave@example.com"}},
+			wantFull:     true,
+			want:         []string{"a:alice@example.com", "d:dave@example.com"},
+		},
+	}
+
+	var previous CollectionState
+	for _, fetch := range fetches {
+		t.Run(fetch.name, func(t *testing.T) {
+			if fetch.ageFullFetch {
+				previous.FullFetchedAt = previous.FullFetchedAt.Add(-fullFetchInterval)
+				if err := NewSyncState(path).save(key, &previous); err != nil {
+					t.Fatalf("failed to age the state: %v", err)
+				}
+			}
+
+			var since time.Time
+			got, _, err := fetchIncremental(ctx, NewSyncState(path), "tenant", UsersEndpoint, func(u User) string { return u.Id },
+				func(ctx context.Context) ([]User, *v2.RateLimitDescription, error) {
+					since, _ = ctx.Value(modifiedSinceKey{}).(time.Time)
+					return fetch.users, nil, nil
+				},
+			)
+			if err != nil {
+				t.Fatalf("fetch failed: %v", err)
+			}
+
+			if fetch.wantFull != since.IsZero() {
+				t.Fatalf("fetched users modified since %s, want a full fetch %t", since, fetch.wantFull)
+			}
+			if !fetch.wantFull && !since.Equal(previous.FetchedAt) {
+				t.Fatalf("fetched users modified since %s, want since the previous fetch at %s", since, previous.FetchedAt)
+			}
+
+			var users []string
+			for _, u := range got {
+				users = append(users, u.Id+":"+u.Email)
+			}
+			if strings.Join(users, ",") != strings.Join(fetch.want, ",") {
+				t.Fatalf("got users %v, want %v", users, fetch.want)
+			}
+
+			cs, err := NewSyncState(path).collection(key)
+			if err != nil {
+				t.Fatalf("failed to read the state: %v", err)
+			}
+
+			if len(cs.Records) != len(fetch.want) || !cs.FetchedAt.After(previous.FetchedAt) {
+				t.Fatalf("saved %d records fetched at %s, want %d fetched after %s", len(cs.Records), cs.FetchedAt, len(fetch.want), previous.FetchedAt)
+			}
+			if fetch.wantFull != cs.FullFetchedAt.Equal(cs.FetchedAt) {
+				t.Fatalf("saved a full fetch at %s and a fetch at %s, want a full fetch %t", cs.FullFetchedAt, cs.FetchedAt, fetch.wantFull)
+			}
+
+			previous = cs
+		})
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("got permissions %o, want 600", perm)
+	}
+}
+
+func TestFetchIncrementalWithoutState(t *testing.T) {
+	calls := 0
+	fetch := func(ctx context.Context) ([]User, *v2.RateLimitDescription, error) {
+		calls++
+		if _, ok := ctx.Value(modifiedSinceKey{}).(time.Time); ok {
+			t.Fatal("fetched the users modified since a time without a sync state")
+		}
+		return []User{{Id: "a"}}, nil, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		got, _, err := fetchIncremental(context.Background(), nil, "tenant", UsersEndpoint, func(u User) string { return u.Id }, fetch)
+		if err != nil || len(got) != 1 {
+			t.Fatalf("got %v, %v, want one user", got, err)
+		}
+	}
+
+	if calls != 2 {
+		t.Fatalf("fetched %d times, want every time", calls)
+	}
+}
@@ -0,0 +1,95 @@
+package xero
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+// TenantFilter selects which connected tenants are synced.
+type TenantFilter struct {
+	// Allow lists the tenant IDs to include. Empty means every tenant is included unless NamePatterns is set.
+	Allow []string
+	// Deny lists the tenant IDs to exclude. Deny always wins over Allow and NamePatterns.
+	Deny []string
+	// NamePatterns lists case-insensitive glob patterns matched against the tenant name.
+	NamePatterns []string
+}
+
+// Match reports whether the connection is selected by the filter, and a reason when it is not.
+func (f *TenantFilter) Match(conn Connection) (bool, string) {
+	if f == nil {
+		return true, ""
+	}
+
+	for _, id := range f.Deny {
+		if strings.EqualFold(id, conn.TenantId) {
+			return false, "tenant id is denied"
+		}
+	}
+
+	if len(f.Allow) == 0 && len(f.NamePatterns) == 0 {
+		return true, ""
+	}
+
+	for _, id := range f.Allow {
+		if strings.EqualFold(id, conn.TenantId) {
+			return true, ""
+		}
+	}
+
+	name := strings.ToLower(conn.TenantName)
+	for _, pattern := range f.NamePatterns {
+		// patterns are validated upfront, so a malformed one simply never matches
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true, ""
+		}
+	}
+
+	return false, "tenant is not in the allowlist and its name matches no pattern"
+}
+
+// ValidateNamePattern returns an error if the tenant name pattern is malformed.
+func ValidateNamePattern(pattern string) error {
+	_, err := path.Match(pattern, "")
+	return err
+}
+
+// Selects reports whether the connection is selected by the tenant filter of the client.
+func (c *Client) Selects(conn Connection) bool {
+	ok, _ := c.tenantFilter.Match(conn)
+	return ok
+}
+
+// GetTenants returns the connections selected by the tenant filter of the client.
+// Excluded connections are logged so that a missing organization can be explained.
+func (c *Client) GetTenants(ctx context.Context) ([]Connection, error) {
+	conns, err := c.GetConnections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	l := ctxzap.Extract(ctx)
+
+	var rv []Connection
+	for _, conn := range conns {
+		ok, reason := c.tenantFilter.Match(conn)
+		if !ok {
+			l.Info(
+				"xero-connector: skipping tenant excluded by filter",
+				zap.String("tenant_id", conn.TenantId),
+				zap.String("tenant_name", conn.TenantName),
+				zap.String("tenant_type", conn.TenantType),
+				zap.String("reason", reason),
+			)
+			continue
+		}
+
+		rv = append(rv, conn)
+	}
+
+	return rv, nil
+}
@@ -0,0 +1,90 @@
+package xero
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// msDatePattern matches the Microsoft JSON dates returned by the Accounting API, like
+// /Date(1573755038314+0000)/. The offset is informational, the milliseconds are UTC.
+var msDatePattern = regexp.MustCompile(`^/Date\((-?\d+)([+-]\d{4})?\)/$`)
+
+// isoLayouts are the ISO 8601 forms used across the Xero APIs, some of them without a zone
+// for times that are UTC.
+var isoLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// Time is a timestamp returned by a Xero API, either as a Microsoft JSON date or in ISO 8601.
+// It is marshalled in RFC 3339, and a zero Time is marshalled as null.
+type Time struct {
+	time.Time
+}
+
+// ParseTime parses a timestamp in any of the forms returned by the Xero APIs.
+func ParseTime(s string) (Time, error) {
+	if s == "" {
+		return Time{}, nil
+	}
+
+	if m := msDatePattern.FindStringSubmatch(s); m != nil {
+		ms, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return Time{}, fmt.Errorf("invalid date %q: %w", s, err)
+		}
+
+		return Time{time.UnixMilli(ms).UTC()}, nil
+	}
+
+	for _, layout := range isoLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return Time{t.UTC()}, nil
+		}
+	}
+
+	return Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*t = Time{}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid date %s: %w", data, err)
+	}
+
+	parsed, err := ParseTime(s)
+	if err != nil {
+		return err
+	}
+
+	*t = parsed
+
+	return nil
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(t.UTC().Format(time.RFC3339Nano))
+}
+
+// String returns the time in RFC 3339, or an empty string for a zero Time.
+func (t Time) String() string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,86 @@
+package xero
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "empty", in: "", want: time.Time{}},
+		{name: "ms date", in: "/Date(1573755038314)/", want: time.Date(2019, 11, 14, 18, 10, 38, 314e6, time.UTC)},
+		{name: "ms date utc offset", in: "/Date(1573755038314+0000)/", want: time.Date(2019, 11, 14, 18, 10, 38, 314e6, time.UTC)},
+		{name: "ms date positive offset", in: "/Date(1573755038314+1300)/", want: time.Date(2019, 11, 14, 18, 10, 38, 314e6, time.UTC)},
+		{name: "ms date negative offset", in: "/Date(1573755038314-0500)/", want: time.Date(2019, 11, 14, 18, 10, 38, 314e6, time.UTC)},
+		{name: "ms date before epoch", in: "/Date(-86400000+0000)/", want: time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{name: "ms date epoch", in: "/Date(0)/", want: time.Unix(0, 0).UTC()},
+		{name: "rfc 3339", in: "2019-11-14T18:10:38Z", want: time.Date(2019, 11, 14, 18, 10, 38, 0, time.UTC)},
+		{name: "rfc 3339 offset", in: "2019-11-15T07:10:38+13:00", want: time.Date(2019, 11, 14, 18, 10, 38, 0, time.UTC)},
+		{name: "iso without zone", in: "2019-11-14T18:10:38.314", want: time.Date(2019, 11, 14, 18, 10, 38, 314e6, time.UTC)},
+		{name: "iso date", in: "2019-11-14", want: time.Date(2019, 11, 14, 0, 0, 0, 0, time.UTC)},
+		{name: "malformed ms date", in: "/Date(abc)/", wantErr: true},
+		{name: "ms date with short offset", in: "/Date(1573755038314+13)/", wantErr: true},
+		{name: "ms date overflow", in: "/Date(99999999999999999999)/", wantErr: true},
+		{name: "garbage", in: "yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTime(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsed %q as %v, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse %q: %v", tt.in, err)
+			}
+
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Fatalf("parsed %q as %v, want %v", tt.in, got.Time, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		wantJSON string
+	}{
+		{name: "null", in: `null`, wantJSON: `null`},
+		{name: "ms date", in: `"/Date(1573755038314+0000)/"`, wantJSON: `"2019-11-14T18:10:38.314Z"`},
+		{name: "iso", in: `"2019-11-15T07:10:38+13:00"`, wantJSON: `"2019-11-14T18:10:38Z"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Time
+			if err := json.Unmarshal([]byte(tt.in), &got); err != nil {
+				t.Fatalf("failed to unmarshal %s: %v", tt.in, err)
+			}
+
+			data, err := json.Marshal(got)
+			if err != nil {
+				t.Fatalf("failed to marshal %v: %v", got, err)
+			}
+
+			if string(data) != tt.wantJSON {
+				t.Fatalf("got %s, want %s", data, tt.wantJSON)
+			}
+		})
+	}
+
+	var got Time
+	if err := json.Unmarshal([]byte(`12`), &got); err == nil {
+		t.Fatal("unmarshalling a number succeeded, want an error")
+	}
+}
@@ -0,0 +1,44 @@
+package xero
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TokenClaims are the claims of a Xero access token relevant to the connector.
+type TokenClaims struct {
+	Expiry                int64    `json:"exp"`
+	ClientId              string   `json:"client_id"`
+	Scopes                []string `json:"scope"`
+	XeroUserId            string   `json:"xero_userid"`
+	AuthenticationEventId string   `json:"authentication_event_id"`
+}
+
+// ExpiresAt returns when the access token expires.
+func (c *TokenClaims) ExpiresAt() time.Time {
+	return time.Unix(c.Expiry, 0)
+}
+
+// ParseTokenClaims decodes the claims of a Xero access token. The signature is not
+// verified, the claims are only used for diagnostics.
+func ParseTokenClaims(token string) (*TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("access token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode access token claims: %w", err)
+	}
+
+	var claims TokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse access token claims: %w", err)
+	}
+
+	return &claims, nil
+}
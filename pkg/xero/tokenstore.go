@@ -0,0 +1,197 @@
+package xero
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// RefreshTokenLifetime is how long Xero keeps an unused refresh token valid.
+	RefreshTokenLifetime = 60 * 24 * time.Hour
+
+	// refreshTokenExpiryWarning is how close to the end of its lifetime a stored refresh token triggers a warning.
+	refreshTokenExpiryWarning = 7 * 24 * time.Hour
+)
+
+// StoredToken is a refresh token persisted between runs.
+type StoredToken struct {
+	RefreshToken string    `json:"refresh_token"`
+	IssuedAt     time.Time `json:"issued_at"`
+}
+
+// ExpiresAt returns when the refresh token expires if it is not used before.
+func (t *StoredToken) ExpiresAt() time.Time {
+	return t.IssuedAt.Add(RefreshTokenLifetime)
+}
+
+// TokenStore persists the refresh token Xero rotates on every use.
+type TokenStore interface {
+	// Load returns the stored token, or nil if nothing has been stored yet.
+	Load(ctx context.Context) (*StoredToken, error)
+	Save(ctx context.Context, token *StoredToken) error
+}
+
+// FileTokenStore stores the refresh token in a local file readable only by the current user.
+// When an encryption key is set, the file content is encrypted with AES-256-GCM.
+type FileTokenStore struct {
+	path string
+	key  []byte
+}
+
+type encryptedTokenFile struct {
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func NewFileTokenStore(path, encryptionKey string) *FileTokenStore {
+	store := &FileTokenStore{path: path}
+
+	if encryptionKey != "" {
+		key := sha256.Sum256([]byte(encryptionKey))
+		store.key = key[:]
+	}
+
+	return store
+}
+
+func (s *FileTokenStore) Load(_ context.Context) (*StoredToken, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to read token store: %w", err)
+	}
+
+	if s.key != nil {
+		var encrypted encryptedTokenFile
+		if err := json.Unmarshal(data, &encrypted); err != nil {
+			return nil, fmt.Errorf("failed to parse token store: %w", err)
+		}
+
+		data, err = s.decrypt(encrypted.Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt token store: %w", err)
+		}
+	} else {
+		var encrypted encryptedTokenFile
+		if err := json.Unmarshal(data, &encrypted); err == nil && encrypted.Ciphertext != nil {
+			return nil, fmt.Errorf("token store is encrypted, an encryption key is required")
+		}
+	}
+
+	var token StoredToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("failed to parse token store: %w", err)
+	}
+
+	return &token, nil
+}
+
+func (s *FileTokenStore) Save(_ context.Context, token *StoredToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	if s.key != nil {
+		ciphertext, err := s.encrypt(data)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt token store: %w", err)
+		}
+
+		data, err = json.Marshal(encryptedTokenFile{Ciphertext: ciphertext})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to write token store: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileTokenStore) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (s *FileTokenStore) encrypt(plaintext []byte) ([]byte, error) {
+	aead, err := s.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (s *FileTokenStore) decrypt(ciphertext []byte) ([]byte, error) {
+	aead, err := s.aead()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, sealed, nil)
+}
+
+// writeFileAtomic replaces the file at path with data, so that readers never observe a partial write.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
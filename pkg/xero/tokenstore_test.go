@@ -0,0 +1,126 @@
+package xero
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileTokenStore(t *testing.T) {
+	issuedAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		encryptionKey string
+		loadKey       string
+		wantPlaintext bool
+		wantErr       bool
+	}{
+		{name: "plaintext", wantPlaintext: true},
+		{name: "encrypted", encryptionKey: "passphrase", loadKey: "passphrase"},
+		{name: "wrong key", encryptionKey: "passphrase", loadKey: "other passphrase", wantErr: true},
+		{name: "missing key", encryptionKey: "passphrase", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "state", "token.json")
+
+			token := &StoredToken{RefreshToken: "rotated-refresh-token", IssuedAt: issuedAt}
+			if err := NewFileTokenStore(path, tt.encryptionKey).Save(ctx, token); err != nil {
+				t.Fatalf("failed to save token: %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read token file: %v", err)
+			}
+
+			if got := strings.Contains(string(data), token.RefreshToken); got != tt.wantPlaintext {
+				t.Fatalf("token file contains the token in plaintext: %v, want %v", got, tt.wantPlaintext)
+			}
+
+			loaded, err := NewFileTokenStore(path, tt.loadKey).Load(ctx)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("loading the token succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to load token: %v", err)
+			}
+
+			if loaded.RefreshToken != token.RefreshToken || !loaded.IssuedAt.Equal(issuedAt) {
+				t.Fatalf("loaded %+v, want %+v", loaded, token)
+			}
+		})
+	}
+}
+
+func TestFileTokenStoreEncryptionIsRandomized(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	token := &StoredToken{RefreshToken: "rotated-refresh-token"}
+
+	var files []string
+	for _, name := range []string{"a.json", "b.json"} {
+		path := filepath.Join(dir, name)
+		if err := NewFileTokenStore(path, "passphrase").Save(ctx, token); err != nil {
+			t.Fatalf("failed to save token: %v", err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read token file: %v", err)
+		}
+		files = append(files, string(data))
+	}
+
+	if files[0] == files[1] {
+		t.Fatal("the same token was encrypted to the same ciphertext twice")
+	}
+}
+
+func TestFileTokenStoreLoadMissing(t *testing.T) {
+	token, err := NewFileTokenStore(filepath.Join(t.TempDir(), "token.json"), "").Load(context.Background())
+	if err != nil || token != nil {
+		t.Fatalf("got %+v, %v, want no token and no error", token, err)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token.json")
+
+	for _, content := range []string{"first", "second"} {
+		if err := writeFileAtomic(path, []byte(content)); err != nil {
+			t.Fatalf("failed to write %q: %v", content, err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != content {
+			t.Fatalf("got %q, %v, want %q", data, err, content)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("got permissions %o, want 600", perm)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d files, want only the written one, temporary files must be removed", len(entries))
+	}
+}
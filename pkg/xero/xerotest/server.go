@@ -0,0 +1,349 @@
+// Package xerotest provides an in-process fake of the Xero identity server, connections
+// endpoint and Accounting API for tests.
+package xerotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/conductorone/baton-xero/pkg/xero"
+)
+
+const (
+	ClientId     = "test-client-id"
+	ClientSecret = "test-client-secret"
+	RefreshToken = "test-refresh-token"
+
+	tokenPath       = "/connect/token"
+	connectionsPath = "/connections"
+	apiPath         = "/api.xro/2.0"
+)
+
+// Tenant is an organisation connected to the app, with its users.
+type Tenant struct {
+	Id string
+	// ConnectionId is the id of the connection to the tenant, generated when empty.
+	ConnectionId string
+	// AuthEventId is the id of the authorization that connected the tenant, generated when empty.
+	AuthEventId  string
+	Name         string
+	Type         string
+	Organisation xero.Organization
+	Users        []xero.User
+}
+
+// Fault is a failure injected in the responses of an endpoint.
+type Fault struct {
+	// StatusCode is the status returned instead of the regular response.
+	StatusCode int
+	// RetryAfter is the Retry-After header of a 429 response, in seconds.
+	RetryAfter int
+	// Times is how many requests fail, 0 means one.
+	Times int
+}
+
+// Server is a fake Xero. Its fixtures and faults may be changed while it is serving.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	tenants  []*Tenant
+	faults   map[string][]Fault
+	tokens   map[string]bool
+	issued   int
+	requests map[string]int
+}
+
+// NewServer starts a fake Xero serving the given tenants. The caller closes it, usually
+// with t.Cleanup(s.Close).
+func NewServer(tenants ...*Tenant) *Server {
+	s := &Server{
+		tenants:  tenants,
+		faults:   make(map[string][]Fault),
+		tokens:   make(map[string]bool),
+		requests: make(map[string]int),
+	}
+
+	for i, t := range tenants {
+		if t.ConnectionId == "" {
+			t.ConnectionId = fmt.Sprintf("00000000-0000-0000-0000-%012d", i+1)
+		}
+		if t.AuthEventId == "" {
+			t.AuthEventId = fmt.Sprintf("00000000-0000-0000-0001-%012d", i+1)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(tokenPath, s.handleToken)
+	mux.HandleFunc(connectionsPath, s.authorized(s.handleConnections))
+	mux.HandleFunc(connectionsPath+"/", s.authorized(s.handleDeleteConnection))
+	mux.HandleFunc(apiPath+xero.UsersEndpoint, s.authorized(s.tenant(s.handleUsers)))
+	mux.HandleFunc(apiPath+xero.OrgsEndpoint, s.authorized(s.tenant(s.handleOrganisations)))
+
+	s.Server = httptest.NewServer(s.count(mux))
+
+	return s
+}
+
+// Endpoints returns the endpoints pointing the client at the fake.
+func (s *Server) Endpoints() *xero.Endpoints {
+	endpoints, err := xero.ParseEndpoints(s.URL+apiPath, s.URL, s.URL+connectionsPath)
+	if err != nil {
+		panic(err)
+	}
+
+	return endpoints
+}
+
+// Inject makes the next requests to the path, like "/Users" or "/connections", fail.
+// Faults are applied in the order they were injected.
+func (s *Server) Inject(path string, fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if fault.Times == 0 {
+		fault.Times = 1
+	}
+
+	s.faults[path] = append(s.faults[path], fault)
+}
+
+// RevokeTokens invalidates every access token issued, so that the next API request is
+// rejected with 401 until the client obtains a new token.
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = make(map[string]bool)
+}
+
+// Requests returns how many requests were received for the path, like "/Users" or "/connect/token".
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[path]
+}
+
+// TokensIssued returns how many access tokens were issued.
+func (s *Server) TokensIssued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.issued
+}
+
+func endpointPath(r *http.Request) string {
+	return strings.TrimPrefix(r.URL.Path, apiPath)
+}
+
+func (s *Server) count(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[endpointPath(r)]++
+		s.mu.Unlock()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// fault returns the fault to apply to the request, if any.
+func (s *Server) fault(path string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	faults := s.faults[path]
+	if len(faults) == 0 {
+		return nil
+	}
+
+	fault := faults[0]
+	faults[0].Times--
+	if faults[0].Times == 0 {
+		s.faults[path] = faults[1:]
+	}
+
+	return &fault
+}
+
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		s.mu.Lock()
+		valid := s.tokens[token]
+		s.mu.Unlock()
+
+		if !valid {
+			writeProblem(w, http.StatusUnauthorized, "Unauthorized", "AuthenticationUnsuccessful")
+			return
+		}
+
+		if fault := s.fault(endpointPath(r)); fault != nil {
+			writeFault(w, fault)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func (s *Server) tenant(next func(w http.ResponseWriter, r *http.Request, tenant *Tenant)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenantId := r.Header.Get("xero-tenant-id")
+
+		s.mu.Lock()
+		var tenant *Tenant
+		for _, t := range s.tenants {
+			if strings.EqualFold(t.Id, tenantId) {
+				tenant = t
+			}
+		}
+		s.mu.Unlock()
+
+		if tenant == nil {
+			writeProblem(w, http.StatusForbidden, "Forbidden", "AuthorizationUnsuccessful")
+			return
+		}
+
+		w.Header().Set("X-MinLimit-Remaining", strconv.Itoa(xero.MinuteLimit-1))
+		w.Header().Set("X-DayLimit-Remaining", strconv.Itoa(xero.DayLimit-1))
+		w.Header().Set("X-AppMinLimit-Remaining", strconv.Itoa(xero.AppMinuteLimit-1))
+
+		next(w, r, tenant)
+	}
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if fault := s.fault(tokenPath); fault != nil {
+		writeFault(w, fault)
+		return
+	}
+
+	clientId, clientSecret, ok := r.BasicAuth()
+	if !ok || clientId != ClientId || clientSecret != ClientSecret {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": xero.TokenErrorInvalidClient})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "client_credentials":
+	case "refresh_token":
+		if r.PostForm.Get("refresh_token") != RefreshToken {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": xero.TokenErrorInvalidGrant})
+			return
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	s.mu.Lock()
+	s.issued++
+	token := fmt.Sprintf("access-token-%d", s.issued)
+	s.tokens[token] = true
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, xero.TokenResponse{
+		AccessToken:  token,
+		RefreshToken: RefreshToken,
+		ExpiresIn:    1800,
+	})
+}
+
+func (s *Server) handleConnections(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	conns := make([]xero.Connection, 0, len(s.tenants))
+	for _, t := range s.tenants {
+		tenantType := t.Type
+		if tenantType == "" {
+			tenantType = "ORGANISATION"
+		}
+
+		conns = append(conns, xero.Connection{
+			Id:          t.ConnectionId,
+			AuthEventId: t.AuthEventId,
+			TenantId:    t.Id,
+			TenantType:  tenantType,
+			TenantName:  t.Name,
+		})
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, conns)
+}
+
+// handleDeleteConnection disconnects the tenant of the connection, whose API requests are
+// then forbidden.
+func (s *Server) handleDeleteConnection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	connectionId := strings.TrimPrefix(r.URL.Path, connectionsPath+"/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, t := range s.tenants {
+		if strings.EqualFold(t.ConnectionId, connectionId) {
+			s.tenants = append(s.tenants[:i:i], s.tenants[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	writeProblem(w, http.StatusNotFound, "Not Found", "")
+}
+
+func (s *Server) handleUsers(w http.ResponseWriter, _ *http.Request, tenant *Tenant) {
+	writeJSON(w, http.StatusOK, xero.UsersResponse{Users: tenant.Users})
+}
+
+func (s *Server) handleOrganisations(w http.ResponseWriter, _ *http.Request, tenant *Tenant) {
+	writeJSON(w, http.StatusOK, xero.OrgResponse{Orgs: []xero.Organization{tenant.Organisation}})
+}
+
+func writeFault(w http.ResponseWriter, fault *Fault) {
+	switch fault.StatusCode {
+	case http.StatusTooManyRequests:
+		w.Header().Set("Retry-After", strconv.Itoa(fault.RetryAfter))
+		w.Header().Set("X-Rate-Limit-Problem", "minute")
+		w.Header().Set("X-MinLimit-Remaining", "0")
+		writeProblem(w, fault.StatusCode, "Too Many Requests", "")
+	case http.StatusUnauthorized:
+		writeProblem(w, fault.StatusCode, "Unauthorized", "AuthenticationUnsuccessful")
+	case http.StatusForbidden:
+		writeProblem(w, fault.StatusCode, "Forbidden", "AuthorizationUnsuccessful")
+	case http.StatusServiceUnavailable:
+		w.WriteHeader(fault.StatusCode)
+		fmt.Fprint(w, "The Organisation is offline")
+	default:
+		writeProblem(w, fault.StatusCode, http.StatusText(fault.StatusCode), "")
+	}
+}
+
+func writeProblem(w http.ResponseWriter, statusCode int, title, detail string) {
+	writeJSON(w, statusCode, map[string]interface{}{
+		"Title":  title,
+		"Status": statusCode,
+		"Detail": detail,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}